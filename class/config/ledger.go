@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultProcessedEventsRetention = 30 * 24 * time.Hour
+
+// ProcessedEventsRetention returns how long processed event IDs are kept, from
+// PROCESSED_EVENTS_RETENTION (a Go duration such as "720h"), defaulting to 30 days.
+func ProcessedEventsRetention() (time.Duration, error) {
+	value := os.Getenv("PROCESSED_EVENTS_RETENTION")
+	if value == "" {
+		return defaultProcessedEventsRetention, nil
+	}
+
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid PROCESSED_EVENTS_RETENTION %q", value)
+	}
+	return retention, nil
+}
@@ -1,42 +1,131 @@
 package config
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"leecho/events"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+const (
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+)
+
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+	StateClosed       ConnectionState = "closed"
+)
+
+// PublishPolicy decides what happens to messages published while RabbitMQ is unreachable.
+type PublishPolicy string
+
+const (
+	// PublishReject fails the publish right away.
+	PublishReject PublishPolicy = "reject"
+	// PublishBuffer keeps the message in memory, up to the buffer size, and sends it after reconnecting.
+	PublishBuffer PublishPolicy = "buffer"
+)
+
+var (
+	ErrDisconnected      = errors.New("rabbitmq is disconnected")
+	ErrPublishBufferFull = errors.New("rabbitmq publish buffer is full")
+)
+
+// RabbitMQConfig holds the RabbitMQ connection of the service. It watches the connection,
+// reconnects with backoff when the broker goes away, then declares the topology again and
+// re-registers the consumers.
 type RabbitMQConfig struct {
-	Connection *amqp.Connection
-	Channel    *amqp.Channel
+	url           string
+	publishPolicy PublishPolicy
+	bufferSize    int
+
+	mu          sync.RWMutex
+	connection  *amqp.Connection
+	channel     *amqp.Channel
+	confirms    *publisherConfirms
+	state       ConnectionState
+	connectedAt time.Time
+	reconnects  int
+	lastError   string
+	buffered    []pendingPublish
+	topology    events.Topology
+	declared    bool
+	consumers   []eventConsumer
+	onDead      func(DeadLetter)
+
+	metrics *PublishMetrics
+}
+
+// session is one connection with its publishing channel in confirm mode.
+type session struct {
+	connection *amqp.Connection
+	channel    *amqp.Channel
+	confirms   *publisherConfirms
+	closed     <-chan *amqp.Error
+}
+
+// pendingPublish is a message waiting for the reconnection under the buffer policy.
+type pendingPublish struct {
+	ctx        context.Context
+	exchange   string
+	routingKey string
+	publishing amqp.Publishing
+	result     chan error
+}
+
+// RabbitMQStatus is a snapshot of the connection, reported by the health endpoint.
+type RabbitMQStatus struct {
+	State         ConnectionState `json:"state"`
+	ConnectedAt   *time.Time      `json:"connected_at,omitempty"`
+	Reconnects    int             `json:"reconnects"`
+	LastError     string          `json:"last_error,omitempty"`
+	PublishPolicy PublishPolicy   `json:"publish_policy"`
+	Buffered      int             `json:"buffered"`
 }
 
 // NewRabbitMQConfig initializes a new RabbitMQConfig instance
-func NewRabbitMQConfig(url string) (*RabbitMQConfig, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
+func NewRabbitMQConfig(url string, publishPolicy PublishPolicy, bufferSize int) (*RabbitMQConfig, error) {
+	r := &RabbitMQConfig{
+		url:           url,
+		publishPolicy: publishPolicy,
+		bufferSize:    bufferSize,
+		metrics:       NewPublishMetrics(),
 	}
 
-	channel, err := conn.Channel()
+	session, err := r.dial()
 	if err != nil {
 		return nil, err
 	}
+	r.attach(session)
+	r.markConnected()
+	go r.watch(session.closed)
 
-	return &RabbitMQConfig{
-		Connection: conn,
-		Channel:    channel,
-	}, nil
+	return r, nil
 }
 
 // Close closes the RabbitMQ connection and channel
 func (r *RabbitMQConfig) Close() {
-	if r.Channel != nil {
-		r.Channel.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = StateClosed
+	if r.channel != nil {
+		r.channel.Close()
 	}
-	if r.Connection != nil {
-		r.Connection.Close()
+	if r.connection != nil {
+		r.connection.Close()
 	}
 }
 
@@ -48,9 +137,26 @@ func InitRabbitMQ() (*RabbitMQConfig, error) {
 	host := os.Getenv("RABBITMQ_HOST")
 	port := os.Getenv("RABBITMQ_PORT")
 
+	publishPolicy := PublishPolicy(os.Getenv("RABBITMQ_PUBLISH_POLICY"))
+	if publishPolicy == "" {
+		publishPolicy = PublishReject
+	}
+	if publishPolicy != PublishReject && publishPolicy != PublishBuffer {
+		return nil, fmt.Errorf("unknown RABBITMQ_PUBLISH_POLICY %q", publishPolicy)
+	}
+
+	bufferSize := 1000
+	if value := os.Getenv("RABBITMQ_PUBLISH_BUFFER_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RABBITMQ_PUBLISH_BUFFER_SIZE: %w", err)
+		}
+		bufferSize = size
+	}
+
 	// Construct the connection URL
 	url := "amqp://" + username + ":" + password + "@" + host + ":" + port + "/"
-	rabbitMQ, err := NewRabbitMQConfig(url)
+	rabbitMQ, err := NewRabbitMQConfig(url, publishPolicy, bufferSize)
 	if err != nil {
 		return nil, err
 	}
@@ -58,14 +164,227 @@ func InitRabbitMQ() (*RabbitMQConfig, error) {
 	return rabbitMQ, nil
 }
 
+// Status returns the current state of the connection.
+func (r *RabbitMQConfig) Status() RabbitMQStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := RabbitMQStatus{
+		State:         r.state,
+		Reconnects:    r.reconnects,
+		LastError:     r.lastError,
+		PublishPolicy: r.publishPolicy,
+		Buffered:      len(r.buffered),
+	}
+	if r.state == StateConnected {
+		connectedAt := r.connectedAt
+		status.ConnectedAt = &connectedAt
+	}
+	return status
+}
+
+// Metrics returns the publish metrics of the connection.
+func (r *RabbitMQConfig) Metrics() *PublishMetrics {
+	return r.metrics
+}
+
+// IsConnected reports whether messages can be published right now.
+func (r *RabbitMQConfig) IsConnected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state == StateConnected
+}
+
+// dial opens a connection and a channel in confirm mode, registering for their closure
+// before anything can close them.
+func (r *RabbitMQConfig) dial() (*session, error) {
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	closed := make(chan *amqp.Error, 1)
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		select {
+		case cause := <-connClosed:
+			closed <- cause
+		case cause := <-channelClosed:
+			closed <- cause
+		}
+	}()
+
+	confirms, err := newPublisherConfirms(channel)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &session{connection: conn, channel: channel, confirms: confirms, closed: closed}, nil
+}
+
+func (r *RabbitMQConfig) attach(session *session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connection = session.connection
+	r.channel = session.channel
+	r.confirms = session.confirms
+}
+
+// markConnected opens the connection to publishers and sends the messages buffered meanwhile.
+func (r *RabbitMQConfig) markConnected() {
+	r.mu.Lock()
+	r.state = StateConnected
+	r.connectedAt = time.Now().UTC()
+	buffered := r.buffered
+	r.buffered = nil
+	r.mu.Unlock()
+
+	for _, pending := range buffered {
+		if err := pending.ctx.Err(); err != nil {
+			pending.result <- err
+			continue
+		}
+		pending.result <- r.PublishMessage(pending.ctx, pending.exchange, pending.routingKey, pending.publishing)
+	}
+	if len(buffered) > 0 {
+		log.Printf("Flushed %d buffered messages.", len(buffered))
+	}
+}
+
+// watch waits for the connection or channel to close and reconnects unless the closure was requested.
+func (r *RabbitMQConfig) watch(closed <-chan *amqp.Error) {
+	cause := <-closed
+
+	r.mu.Lock()
+	if r.state == StateClosed {
+		r.mu.Unlock()
+		return
+	}
+	r.state = StateReconnecting
+	if cause != nil {
+		r.lastError = cause.Error()
+	}
+	connection := r.connection
+	r.mu.Unlock()
+
+	log.Printf("RabbitMQ connection lost: %v", cause)
+	connection.Close()
+	r.reconnect()
+}
+
+// reconnect dials until it succeeds, doubling the delay between attempts, then restores the
+// topology and the consumers.
+func (r *RabbitMQConfig) reconnect() {
+	delay := reconnectBaseDelay
+	for {
+		time.Sleep(delay)
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+
+		r.mu.RLock()
+		closing := r.state == StateClosed
+		r.mu.RUnlock()
+		if closing {
+			return
+		}
+
+		log.Println("Reconnecting to RabbitMQ...")
+		session, err := r.dial()
+		if err != nil {
+			r.recordError(err)
+			log.Printf("Failed to reconnect to RabbitMQ: %s", err)
+			continue
+		}
+		r.attach(session)
+
+		if err := r.restore(); err != nil {
+			r.recordError(err)
+			log.Printf("Failed to restore RabbitMQ topology and consumers: %s", err)
+			session.connection.Close()
+			continue
+		}
+
+		r.mu.Lock()
+		r.reconnects++
+		r.mu.Unlock()
+		r.markConnected()
+		go r.watch(session.closed)
+
+		log.Println("RabbitMQ connection recovered successfully!")
+		return
+	}
+}
+
+// restore declares the topology again and re-registers the consumers on the new channel.
+func (r *RabbitMQConfig) restore() error {
+	r.mu.RLock()
+	topology, declared := r.topology, r.declared
+	consumers := append([]eventConsumer(nil), r.consumers...)
+	r.mu.RUnlock()
+
+	if declared {
+		if err := r.declareTopology(topology); err != nil {
+			return err
+		}
+	}
+	for _, consumer := range consumers {
+		if err := r.startConsumer(consumer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RabbitMQConfig) recordError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastError = err.Error()
+}
+
+func (r *RabbitMQConfig) currentChannel() (*amqp.Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.channel == nil || r.state == StateClosed {
+		return nil, ErrDisconnected
+	}
+	return r.channel, nil
+}
+
+func (r *RabbitMQConfig) currentConnection() (*amqp.Connection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.connection == nil || r.state != StateConnected {
+		return nil, ErrDisconnected
+	}
+	return r.connection, nil
+}
+
 func (r *RabbitMQConfig) DeclareQueue(queueName string, durable bool) error {
-	_, err := r.Channel.QueueDeclare(
+	return r.declareQueue(queueName, durable, nil)
+}
+
+func (r *RabbitMQConfig) declareQueue(queueName string, durable bool, args amqp.Table) error {
+	channel, err := r.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	_, err = channel.QueueDeclare(
 		queueName,
 		durable,
 		false,
 		false,
 		false,
-		nil,
+		args,
 	)
 	if err != nil {
 		return err
@@ -74,21 +393,176 @@ func (r *RabbitMQConfig) DeclareQueue(queueName string, durable bool) error {
 	return nil
 }
 
-func (r *RabbitMQConfig) PublishMessage(queueName string, message []byte) error {
-	err := r.Channel.Publish(
-		"",
-		queueName,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        message,
-		})
+// DeclareTopology declares the exchanges, queues and bindings of a topology, along with
+// the delayed retry queues and the dead-letter exchange and queue of every queue.
+// The topology is declared again after every reconnection.
+func (r *RabbitMQConfig) DeclareTopology(topology events.Topology) error {
+	if err := r.declareTopology(topology); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.topology = topology
+	r.declared = true
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RabbitMQConfig) declareTopology(topology events.Topology) error {
+	channel, err := r.currentChannel()
 	if err != nil {
-		log.Printf("Failed to publish message: %s", err)
 		return err
 	}
 
-	log.Printf("Message published to queue %s: %s", queueName, message)
+	exchanges, err := topology.Exchanges()
+	if err != nil {
+		return err
+	}
+	for _, exchange := range exchanges {
+		if err := channel.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+			return err
+		}
+		log.Printf("Exchange '%s' declared successfully.", exchange)
+	}
+
+	for _, queue := range topology.Queues {
+		if err := r.declareDeadLetterQueue(channel, queue.Name); err != nil {
+			return err
+		}
+		if err := r.declareQueue(queue.Name, true, amqp.Table{
+			"x-dead-letter-exchange": events.DeadLetterExchangeName(queue.Name),
+		}); err != nil {
+			return err
+		}
+		if err := r.declareRetryQueues(queue.Name, topology.RetryPolicy()); err != nil {
+			return err
+		}
+		for _, binding := range queue.Bindings {
+			if err := channel.QueueBind(queue.Name, binding.RoutingKey, binding.Exchange, false, nil); err != nil {
+				return err
+			}
+			log.Printf("Queue '%s' bound to '%s' with '%s'.", queue.Name, binding.Exchange, binding.RoutingKey)
+		}
+	}
+	return nil
+}
+
+func (r *RabbitMQConfig) declareDeadLetterQueue(channel *amqp.Channel, queueName string) error {
+	exchange := events.DeadLetterExchangeName(queueName)
+	deadLetterQueue := events.DeadLetterQueueName(queueName)
+
+	if err := channel.ExchangeDeclare(exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := r.declareQueue(deadLetterQueue, true, nil); err != nil {
+		return err
+	}
+	return channel.QueueBind(deadLetterQueue, "", exchange, false, nil)
+}
+
+// declareRetryQueues declares one delay queue per retry attempt. Messages expire from it
+// after the attempt's delay and are dead-lettered back to the original queue.
+func (r *RabbitMQConfig) declareRetryQueues(queueName string, policy events.RetryPolicy) error {
+	for attempt := 1; attempt <= policy.MaxRetries; attempt++ {
+		if err := r.declareQueue(events.RetryQueueName(queueName, attempt), true, amqp.Table{
+			"x-message-ttl":             int32(policy.Delay(attempt).Milliseconds()),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queueName,
+		}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// VerifyTopology checks that every event type the service emits reaches a queue with a registered consumer.
+func (r *RabbitMQConfig) VerifyTopology(topology events.Topology) error {
+	r.mu.RLock()
+	consumed := map[string]bool{}
+	for _, consumer := range r.consumers {
+		consumed[consumer.queueName] = true
+	}
+	r.mu.RUnlock()
+
+	var unconsumed []string
+	for _, eventType := range topology.Emits {
+		bound := false
+		for _, queue := range topology.Routes(eventType) {
+			if consumed[queue] {
+				bound = true
+				break
+			}
+		}
+		if !bound {
+			unconsumed = append(unconsumed, string(eventType))
+		}
+	}
+	if len(unconsumed) > 0 {
+		return fmt.Errorf("event types without a bound consumer: %s", strings.Join(unconsumed, ", "))
+	}
+	return nil
+}
+
+// PublishEvent publishes an encoded event on the exchange of its type, routed by its type, and
+// returns once the broker confirmed it. Events no queue is bound for fail with ErrUnroutable.
+func (r *RabbitMQConfig) PublishEvent(ctx context.Context, eventType events.Type, eventID string, message []byte) error {
+	exchange, err := events.ExchangeFor(eventType)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = r.PublishMessage(ctx, exchange, eventType.RoutingKey(), amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    eventID,
+		Type:         string(eventType),
+		Body:         message,
+	})
+	r.metrics.Observe(string(eventType), time.Since(start), err)
+	if err != nil {
+		log.Printf("Failed to publish %s event %s: %s", eventType, eventID, err)
+		return err
+	}
+
+	log.Printf("Message published to %s with routing key %s: %s", exchange, eventType.RoutingKey(), message)
+	return nil
+}
+
+// PublishMessage publishes a message with mandatory routing and waits for the broker to confirm it.
+// While disconnected, the publish policy decides whether it fails right away or waits for the
+// reconnection, within the limits of ctx.
+func (r *RabbitMQConfig) PublishMessage(ctx context.Context, exchange, routingKey string, publishing amqp.Publishing) error {
+	r.mu.Lock()
+	if r.state != StateConnected {
+		if r.publishPolicy != PublishBuffer {
+			r.mu.Unlock()
+			return ErrDisconnected
+		}
+		if len(r.buffered) >= r.bufferSize {
+			r.mu.Unlock()
+			return ErrPublishBufferFull
+		}
+		pending := pendingPublish{
+			ctx:        ctx,
+			exchange:   exchange,
+			routingKey: routingKey,
+			publishing: publishing,
+			result:     make(chan error, 1),
+		}
+		r.buffered = append(r.buffered, pending)
+		r.mu.Unlock()
+
+		log.Printf("RabbitMQ is disconnected, buffered message for %s with routing key %s", exchange, routingKey)
+		select {
+		case err := <-pending.result:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	channel, confirms := r.channel, r.confirms
+	r.mu.Unlock()
+
+	return confirms.publish(ctx, channel, exchange, routingKey, publishing)
+}
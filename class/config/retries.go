@@ -0,0 +1,169 @@
+package config
+
+import (
+	"context"
+	"leecho/events"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// republishTimeout bounds how long a consumer waits for the broker to confirm a retried or dead-lettered message.
+const republishTimeout = 10 * time.Second
+
+// eventConsumer is a handler registered on a queue, re-registered after every reconnection.
+type eventConsumer struct {
+	queueName string
+	handler   func(events.Raw) error
+}
+
+// ConsumeEvents decodes the events of a queue in the background and passes them to handler.
+// Handled events are acknowledged; failed ones go through the delayed retry queues and are
+// dead-lettered once the topology's retry policy is exhausted.
+func (r *RabbitMQConfig) ConsumeEvents(queueName string, handler func(events.Raw) error) error {
+	consumer := eventConsumer{queueName: queueName, handler: handler}
+	if err := r.startConsumer(consumer); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.consumers = append(r.consumers, consumer)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RabbitMQConfig) startConsumer(consumer eventConsumer) error {
+	channel, err := r.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	msgs, err := channel.Consume(
+		consumer.queueName,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range msgs {
+			r.handleDelivery(consumer, msg)
+		}
+		log.Printf("Stopped consuming %s", consumer.queueName)
+	}()
+	return nil
+}
+
+func (r *RabbitMQConfig) handleDelivery(consumer eventConsumer, msg amqp.Delivery) {
+	log.Printf("Received a message from %s: %s", consumer.queueName, msg.Body)
+
+	event, err := events.Parse(msg.Body)
+	if err != nil {
+		log.Printf("Failed to unmarshal event from %s: %s", consumer.queueName, err)
+		r.deadLetter(consumer.queueName, msg, err)
+		return
+	}
+
+	if err := consumer.handler(event); err != nil {
+		log.Printf("Failed to handle %s event %s: %s", event.Type, event.ID, err)
+		r.retry(consumer.queueName, msg, err)
+		return
+	}
+
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge event %s: %s", event.ID, err)
+	}
+}
+
+// retry moves a failed message to the delay queue of its next attempt, or dead-letters it
+// when no attempt is left.
+func (r *RabbitMQConfig) retry(queueName string, msg amqp.Delivery, cause error) {
+	r.mu.RLock()
+	policy := r.topology.RetryPolicy()
+	r.mu.RUnlock()
+
+	attempt := retryCount(msg.Headers) + 1
+	if attempt > policy.MaxRetries {
+		r.deadLetter(queueName, msg, cause)
+		return
+	}
+
+	headers := copyHeaders(msg.Headers)
+	headers[events.RetryCountHeader] = int32(attempt)
+	headers[events.LastErrorHeader] = cause.Error()
+
+	r.republish(msg, "", events.RetryQueueName(queueName, attempt), headers)
+	log.Printf("Scheduled retry %d of a message from %s", attempt, queueName)
+}
+
+// deadLetter moves a message to the dead-letter exchange of its queue, recording why.
+func (r *RabbitMQConfig) deadLetter(queueName string, msg amqp.Delivery, cause error) {
+	headers := copyHeaders(msg.Headers)
+	headers[events.LastErrorHeader] = cause.Error()
+	headers[events.DeadLetteredAtHeader] = time.Now().UTC().Format(time.RFC3339)
+
+	r.republish(msg, events.DeadLetterExchangeName(queueName), "", headers)
+	log.Printf("Dead-lettered a message from %s: %s", queueName, cause)
+
+	r.mu.RLock()
+	onDead := r.onDead
+	r.mu.RUnlock()
+	if onDead != nil {
+		msg.Headers = headers
+		onDead(newDeadLetter(queueName, msg))
+	}
+}
+
+// republish publishes a copy of a delivery and acknowledges the original. If the copy cannot be
+// published, the original is rejected so that the queue's dead-letter exchange still receives it.
+func (r *RabbitMQConfig) republish(msg amqp.Delivery, exchange, routingKey string, headers amqp.Table) {
+	ctx, cancel := context.WithTimeout(context.Background(), republishTimeout)
+	defer cancel()
+
+	err := r.PublishMessage(ctx, exchange, routingKey, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageId,
+		Type:         msg.Type,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to republish message: %s", err)
+		if err := msg.Nack(false, false); err != nil {
+			log.Printf("Failed to reject message: %s", err)
+		}
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge message: %s", err)
+	}
+}
+
+func retryCount(headers amqp.Table) int {
+	switch count := headers[events.RetryCountHeader].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	case int:
+		return count
+	default:
+		return 0
+	}
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	copied := amqp.Table{}
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
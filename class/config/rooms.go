@@ -0,0 +1,29 @@
+package config
+
+import (
+	"class/rooms"
+	"fmt"
+	"os"
+)
+
+const defaultVirtualRoomBaseURL = "http://localhost:3000/rooms"
+
+// VirtualRoomProvider returns the provider online classes get their rooms from, as named by
+// VIRTUAL_ROOM_PROVIDER. The default "local" provider signs its links with VIRTUAL_ROOM_SECRET
+// under VIRTUAL_ROOM_BASE_URL.
+func VirtualRoomProvider() (rooms.Provider, error) {
+	switch provider := os.Getenv("VIRTUAL_ROOM_PROVIDER"); provider {
+	case "", "local":
+		secret := os.Getenv("VIRTUAL_ROOM_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("VIRTUAL_ROOM_SECRET is required by the local virtual room provider")
+		}
+		baseURL := os.Getenv("VIRTUAL_ROOM_BASE_URL")
+		if baseURL == "" {
+			baseURL = defaultVirtualRoomBaseURL
+		}
+		return rooms.NewLocalProvider(baseURL, secret), nil
+	default:
+		return nil, fmt.Errorf("unknown VIRTUAL_ROOM_PROVIDER %q", provider)
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultClassSeriesHorizon = 90 * 24 * time.Hour
+
+// ClassSeriesHorizon returns how far ahead the occurrences of class series are materialised, from
+// CLASS_SERIES_HORIZON (a Go duration such as "2160h"), defaulting to 90 days.
+func ClassSeriesHorizon() (time.Duration, error) {
+	value := os.Getenv("CLASS_SERIES_HORIZON")
+	if value == "" {
+		return defaultClassSeriesHorizon, nil
+	}
+
+	horizon, err := time.ParseDuration(value)
+	if err != nil || horizon <= 0 {
+		return 0, fmt.Errorf("invalid CLASS_SERIES_HORIZON %q", value)
+	}
+	return horizon, nil
+}
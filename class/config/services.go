@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultCourseServiceURL reaches the course service by its service name; both services listen on
+// :3000, so localhost would be this service.
+const defaultCourseServiceURL = "http://course:3000"
+
+// CourseServiceURL returns the base URL of the course service, from COURSE_SERVICE_URL.
+func CourseServiceURL() (string, error) {
+	value := os.Getenv("COURSE_SERVICE_URL")
+	if value == "" {
+		return defaultCourseServiceURL, nil
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("invalid COURSE_SERVICE_URL %q", value)
+	}
+	return strings.TrimRight(value, "/"), nil
+}
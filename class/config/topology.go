@@ -0,0 +1,41 @@
+package config
+
+import "leecho/events"
+
+// Topology is the RabbitMQ layout of the class service, declared at startup.
+var Topology = events.Topology{
+	Queues: []events.Queue{
+		{
+			Name: "class_events",
+			Bindings: []events.Binding{
+				{Exchange: events.ClassExchange, RoutingKey: "class.*"},
+				{Exchange: events.ClassExchange, RoutingKey: "class_series.*"},
+				{Exchange: events.ClassExchange, RoutingKey: "enrollment.*"},
+				{Exchange: events.ClassExchange, RoutingKey: "attendance.*"},
+				{Exchange: events.ClassExchange, RoutingKey: "feedback.*"},
+			},
+		},
+		{
+			Name: "class_course_events",
+			Bindings: []events.Binding{
+				{Exchange: events.CourseExchange, RoutingKey: "course.*"},
+			},
+		},
+	},
+	Emits: []events.Type{
+		events.ClassCreated,
+		events.ClassUpdated,
+		events.ClassDeleted,
+		events.ClassCancelled,
+		events.ClassRescheduled,
+		events.ClassSeriesCreated,
+		events.ClassSeriesUpdated,
+		events.ClassSeriesDeleted,
+		events.LearnerEnrolled,
+		events.LearnerWaitlisted,
+		events.LearnerPromoted,
+		events.EnrollmentCancelled,
+		events.AttendanceRecorded,
+		events.FeedbackSubmitted,
+	},
+}
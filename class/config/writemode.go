@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// WriteMode decides how the API carries out write commands.
+type WriteMode string
+
+const (
+	// WriteModeAsync publishes the command as an event, applied later by the consumer.
+	// The API answers 202 with an operation to poll.
+	WriteModeAsync WriteMode = "async"
+	// WriteModeSync applies the command in a transaction that also writes its event to the
+	// outbox. The API answers with the persisted entity.
+	WriteModeSync WriteMode = "sync"
+)
+
+// GetWriteMode reads WRITE_MODE, defaulting to the asynchronous mode.
+func GetWriteMode() (WriteMode, error) {
+	mode := WriteMode(os.Getenv("WRITE_MODE"))
+	switch mode {
+	case "":
+		return WriteModeAsync, nil
+	case WriteModeAsync, WriteModeSync:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown WRITE_MODE %q", mode)
+	}
+}
@@ -3,78 +3,97 @@ package consumers
 import (
 	"class/config"
 	"class/models"
-	"encoding/json"
+	"class/services"
+	"errors"
+	"fmt"
+	"leecho/events"
 	"log"
 
 	"gorm.io/gorm"
 )
 
-type ClassEvent struct {
-	EventType string       `json:"event_type"`
-	Class     models.Class `json:"class"`
-	ID        uint         `json:"id"`
-}
-
 func StartClassEventConsumer(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
-	msgs, err := rabbitMQConfig.Channel.Consume(
-		"class_events",
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err := rabbitMQConfig.ConsumeEvents("class_events", func(event events.Raw) error {
+		// Already applied by the API in synchronous write mode.
+		if event.Persisted {
+			return nil
+		}
+		return processOnce(db, "class_events", event, handleClassEvent)
+	})
 	if err != nil {
 		log.Fatalf("Failed to register a consumer: %s", err)
 	}
 
-	go func() {
-		for msg := range msgs {
-			log.Printf("Received a message: %s", msg.Body)
-
-			var classEvent ClassEvent
-			err := json.Unmarshal(msg.Body, &classEvent)
-			if err != nil {
-				log.Printf("Failed to unmarshal class event data: %s", err)
-				continue
-			}
-
-			switch classEvent.EventType {
-			case "class.created":
-				log.Printf("Handling class created event for class: %s", classEvent.Class.Title)
-				if err := models.CreateClass(db, &classEvent.Class); err != nil {
-					log.Printf("Failed to insert class into the database: %s", err)
-					continue
-				}
-				log.Printf("Class '%s' inserted into the database successfully!", classEvent.Class.Title)
-
-			case "class.updated":
-				log.Printf("Handling class updated event for class: %s", classEvent.Class.Title)
-				if classEvent.Class.ID == 0 {
-					log.Printf("No Class ID provided for update event")
-					continue
-				}
+	log.Println("Waiting for class event messages.")
+}
 
-				if err := models.UpdateClass(db, classEvent.Class.ID, &classEvent.Class); err != nil {
-					log.Printf("Failed to update class in the database: %s", err)
-					continue
-				}
-				log.Printf("Class '%s' updated in the database successfully!", classEvent.Class.Title)
+func handleClassEvent(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.ClassCreated:
+		created, err := events.DecodePayload[models.Class](event)
+		if err != nil {
+			return 0, err
+		}
+		class := created.Payload
+		log.Printf("Handling class created event for class: %s", class.Title)
+		if err := models.CheckReferences(db, class.CourseID, class.InstructorID); err != nil {
+			return 0, err
+		}
+		if err := models.CreateClass(db, &class); err != nil {
+			return 0, fmt.Errorf("inserting class into the database: %w", err)
+		}
+		log.Printf("Class '%s' inserted into the database successfully!", class.Title)
+		return class.ID, enqueueClassFact(db, event, class.ID)
 
-			case "class.deleted":
-				log.Printf("Handling class deleted event for class ID: %d", classEvent.ID)
-				if err := models.DeleteClass(db, classEvent.ID); err != nil {
-					log.Printf("Failed to delete class from the database: %s", err)
-					continue
-				}
-				log.Printf("Class with ID %d deleted from the database successfully!", classEvent.ID)
+	case events.ClassUpdated:
+		updated, err := events.DecodePayload[models.Class](event)
+		if err != nil {
+			return 0, err
+		}
+		class := updated.Payload
+		log.Printf("Handling class updated event for class: %s", class.Title)
+		if class.ID == 0 {
+			return 0, errors.New("no class ID provided for update event")
+		}
+		if err := models.CheckChangedReferences(db, class.CourseID, class.InstructorID); err != nil {
+			return 0, err
+		}
+		if _, err := services.ApplyClassUpdate(db, class.ID, &class, event.CorrelationID); err != nil {
+			return 0, fmt.Errorf("updating class in the database: %w", err)
+		}
+		log.Printf("Class '%s' updated in the database successfully!", class.Title)
+		return class.ID, enqueueClassFact(db, event, class.ID)
 
-			default:
-				log.Printf("Unknown event type: %s", classEvent.EventType)
-			}
+	case events.ClassDeleted:
+		deleted, err := events.DecodePayload[events.Deleted](event)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Handling class deleted event for class ID: %d", deleted.Payload.ID)
+		if err := models.DeleteClass(db, deleted.Payload.ID); err != nil {
+			return 0, fmt.Errorf("deleting class from the database: %w", err)
 		}
-	}()
+		log.Printf("Class with ID %d deleted from the database successfully!", deleted.Payload.ID)
+		fact := events.New(events.ClassDeleted, events.SourceClassService, deleted.Payload).
+			WithCorrelationID(event.CorrelationID).
+			MarkPersisted()
+		return deleted.Payload.ID, models.EnqueueEvent(db, fact)
 
-	log.Println("Waiting for class event messages.")
+	default:
+		log.Printf("Unknown event type: %s", event.Type)
+	}
+	return 0, nil
+}
+
+// enqueueClassFact records the class a command created or updated in the outbox, as the persisted
+// event other services follow classes by.
+func enqueueClassFact(db *gorm.DB, command events.Raw, classID uint) error {
+	class, err := models.GetClassByID(db, classID)
+	if err != nil {
+		return err
+	}
+	fact := events.New(command.Type, events.SourceClassService, *class).
+		WithCorrelationID(command.CorrelationID).
+		MarkPersisted()
+	return models.EnqueueEvent(db, fact)
 }
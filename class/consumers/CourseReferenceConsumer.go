@@ -0,0 +1,53 @@
+package consumers
+
+import (
+	"class/config"
+	"class/services"
+	"leecho/events"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// StartCourseReferenceConsumer keeps the courses and instructors classes may point at current from
+// the events of the course service.
+func StartCourseReferenceConsumer(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	err := rabbitMQConfig.ConsumeEvents("class_course_events", func(event events.Raw) error {
+		// Commands not yet applied by the course service, which publishes the outcome once it is.
+		if !event.Persisted {
+			return nil
+		}
+		return processOnce(db, "class_course_events", event, handleCourseFact)
+	})
+	if err != nil {
+		log.Fatalf("Failed to register a consumer for class_course_events: %s", err)
+	}
+
+	log.Println("Waiting for course event messages.")
+}
+
+func handleCourseFact(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.CourseCreated, events.CourseUpdated:
+		changed, err := events.DecodePayload[services.RemoteCourse](event)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := services.SaveCourse(db, changed.Payload); err != nil {
+			return 0, err
+		}
+		return changed.Payload.ID, nil
+
+	case events.CourseDeleted:
+		deleted, err := events.DecodePayload[events.Deleted](event)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Course %d deleted, cancelling its upcoming classes", deleted.Payload.ID)
+		return deleted.Payload.ID, services.ForgetCourse(db, deleted.Payload.ID, event.CorrelationID)
+
+	default:
+		log.Printf("Unknown event type: %s", event.Type)
+	}
+	return 0, nil
+}
@@ -0,0 +1,21 @@
+package consumers
+
+import (
+	"class/config"
+	"class/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// StartOperationTracker fails the operation carried by an event once its consumer gives up on it.
+func StartOperationTracker(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	rabbitMQConfig.OnDeadLetter(func(deadLetter config.DeadLetter) {
+		if deadLetter.EventID == "" {
+			return
+		}
+		if err := models.FailOperation(db, deadLetter.EventID, deadLetter.Error); err != nil {
+			log.Printf("Failed to fail operation for event %s: %s", deadLetter.EventID, err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package consumers
+
+import (
+	"class/models"
+	"leecho/events"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ledgerPruneInterval = time.Hour
+
+// processOnce handles an event in a transaction that also records it in the consumer's ledger,
+// skipping events the consumer already processed. handle returns the ID of the entity the event
+// touched, which completes the operation carried by the event, if any. When handling fails, the
+// error is kept on the still pending operation while the event is retried.
+func processOnce(db *gorm.DB, consumer string, event events.Raw, handle func(*gorm.DB, events.Raw) (uint, error)) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		first, err := models.RecordProcessedEvent(tx, consumer, event.ID, string(event.Type))
+		if err != nil {
+			return err
+		}
+		if !first {
+			log.Printf("Skipping %s event %s, already processed by %s", event.Type, event.ID, consumer)
+			return nil
+		}
+		entityID, err := handle(tx, event)
+		if err != nil {
+			return err
+		}
+		return models.CompleteOperation(tx, event.ID, entityID)
+	})
+	if err != nil {
+		if recordErr := models.RecordOperationError(db, event.ID, err.Error()); recordErr != nil {
+			log.Printf("Failed to record the error of operation for event %s: %s", event.ID, recordErr)
+		}
+	}
+	return err
+}
+
+// StartProcessedEventLedgerPruner deletes ledger entries older than retention in the background.
+// Retention must exceed the longest time an event can spend in retry and dead-letter queues before
+// being redelivered, or a replayed duplicate would be processed again.
+func StartProcessedEventLedgerPruner(db *gorm.DB, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(ledgerPruneInterval)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			pruned, err := models.PruneProcessedEvents(db, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Failed to prune processed events: %s", err)
+				continue
+			}
+			if pruned > 0 {
+				log.Printf("Pruned %d processed events older than %s", pruned, retention)
+			}
+		}
+	}()
+}
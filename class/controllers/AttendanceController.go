@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"bytes"
+	"class/services"
+	"errors"
+	"io"
+	"leecho/events"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AttendanceController struct {
+	attendanceService *services.AttendanceService
+}
+
+func NewAttendanceController(attendanceService *services.AttendanceService) *AttendanceController {
+	return &AttendanceController{
+		attendanceService: attendanceService,
+	}
+}
+
+// GetAttendance handles the attendance report of a class.
+// @Summary Get the attendance of a class
+// @Description Retrieve the attendees of a class, its no-shows and walk-ins, and its attendance rate among enrolled learners
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Success 200 {object} services.AttendanceReport
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Attendance
+// @Router /class/{id}/attendance [get]
+func (c *AttendanceController) GetAttendance(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	report, err := c.attendanceService.GetAttendanceReport(uint(classID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch attendance"})
+	}
+
+	return ctx.JSON(report)
+}
+
+// CheckIn handles checking learners in on behalf of the instructor.
+// @Summary Check learners in
+// @Description Record the attendance of learners at a class, as its instructor, once check-in opens and unless the class was cancelled. Learners already checked in keep their first check-in.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param attendance body object true "Instructor and learners, as {\"instructor_id\": 1, \"learner_ids\": [1, 2]}"
+// @Success 200 {array} models.Attendance
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Attendance
+// @Router /class/{id}/attendance [post]
+func (c *AttendanceController) CheckIn(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		InstructorID uint   `json:"instructor_id"`
+		LearnerIDs   []uint `json:"learner_ids"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.InstructorID == 0 || len(requestBody.LearnerIDs) == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Instructor ID and learner IDs are required"})
+	}
+
+	attendances, err := c.attendanceService.CheckIn(uint(classID), requestBody.InstructorID, requestBody.LearnerIDs, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, services.ErrCheckInClosed) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Check-in is closed for this class"})
+	}
+	if err != nil {
+		return attendanceError(ctx, err, "Could not record attendance")
+	}
+
+	return ctx.JSON(attendances)
+}
+
+// ImportAttendance handles importing the attendee list of a class.
+// @Summary Import attendees
+// @Description Record the attendees of a class from a CSV list exported by a webinar tool, sent as the "file" form field or as the request body. The list needs a learner ID column; join time and duration (minutes) columns are optional. Invalid lines are skipped and reported.
+// @Accept multipart/form-data,text/csv
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param instructor_id query uint true "Instructor ID"
+// @Param file formData file false "Attendee list"
+// @Success 200 {object} services.ImportResult
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Attendance
+// @Router /class/{id}/attendance/import [post]
+func (c *AttendanceController) ImportAttendance(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+	instructorID := ctx.QueryInt("instructor_id")
+	if instructorID <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Instructor ID is required"})
+	}
+
+	var list io.Reader = bytes.NewReader(ctx.Body())
+	if header, err := ctx.FormFile("file"); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid file"})
+		}
+		defer file.Close()
+		list = file
+	}
+
+	result, err := c.attendanceService.ImportAttendance(uint(classID), uint(instructorID), list, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, services.ErrInvalidAttendees) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return attendanceError(ctx, err, "Could not import attendance")
+	}
+
+	return ctx.JSON(result)
+}
+
+// IssueCheckInCode handles generating the self check-in code of a class.
+// @Summary Issue a check-in code
+// @Description Generate the code enrolled learners check themselves in with, from 15 minutes before the class until it ends. The previous code is revoked.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param instructor body object true "Instructor, as {\"instructor_id\": 1}"
+// @Success 201 {object} services.CheckInWindow
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Attendance
+// @Router /class/{id}/check-in-code [post]
+func (c *AttendanceController) IssueCheckInCode(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		InstructorID uint `json:"instructor_id"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.InstructorID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Instructor ID is required"})
+	}
+
+	window, err := c.attendanceService.IssueCheckInCode(uint(classID), requestBody.InstructorID)
+	if err != nil {
+		return attendanceError(ctx, err, "Could not issue check-in code")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(window)
+}
+
+// SelfCheckIn handles a learner checking themselves in.
+// @Summary Check in
+// @Description Record the attendance of an enrolled learner presenting the check-in code of a class, from 15 minutes before it starts until it ends
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param check_in body object true "Learner and code, as {\"learner_id\": 1, \"code\": \"ABC234\"}"
+// @Success 201 {object} models.Attendance
+// @Success 200 {object} models.Attendance
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Attendance
+// @Router /class/{id}/check-in [post]
+func (c *AttendanceController) SelfCheckIn(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		LearnerID uint   `json:"learner_id"`
+		Code      string `json:"code"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.LearnerID == 0 || requestBody.Code == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Learner ID and code are required"})
+	}
+
+	attendance, recorded, err := c.attendanceService.SelfCheckIn(uint(classID), requestBody.LearnerID, requestBody.Code, ctx.Get(events.CorrelationHeader))
+	switch {
+	case errors.Is(err, services.ErrCheckInClosed):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Check-in is closed for this class"})
+	case errors.Is(err, services.ErrInvalidCheckInCode):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Invalid check-in code"})
+	case errors.Is(err, services.ErrNotEnrolled):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Learner is not enrolled in this class"})
+	case err != nil:
+		return attendanceError(ctx, err, "Could not check in")
+	}
+
+	if !recorded {
+		return ctx.Status(fiber.StatusOK).JSON(attendance)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(attendance)
+}
+
+// attendanceError answers the errors shared by the attendance endpoints, or 500 with message.
+func attendanceError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	case errors.Is(err, services.ErrNotClassInstructor):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only the instructor of the class may do this"})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"class/ical"
+	"class/models"
+	"class/services"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CalendarController struct {
+	calendarService *services.CalendarService
+}
+
+func NewCalendarController(calendarService *services.CalendarService) *CalendarController {
+	return &CalendarController{
+		calendarService: calendarService,
+	}
+}
+
+// ExportClass handles exporting a class as an iCalendar file.
+// @Summary Export a class
+// @Description Download a class as an iCalendar file, cancelled when the class was deleted
+// @Produce text/calendar
+// @Param id path uint true "Class ID"
+// @Success 200 {string} string
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Tags Calendars
+// @Router /class/{id}.ics [get]
+func (c *CalendarController) ExportClass(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	calendar, err := c.calendarService.ClassCalendar(uint(classID))
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+
+	ctx.Attachment(fmt.Sprintf("class-%d.ics", classID))
+	return sendCalendar(ctx, calendar)
+}
+
+// CompanyFeed handles the calendar feed of a company.
+// @Summary Company calendar feed
+// @Description Subscribable iCalendar feed of the classes of a company
+// @Produce text/calendar
+// @Param id path uint true "Company ID"
+// @Success 200 {string} string
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Calendars
+// @Router /calendars/companies/{id}.ics [get]
+func (c *CalendarController) CompanyFeed(ctx *fiber.Ctx) error {
+	return c.feed(ctx, "company", func(id uint) models.ClassFilter { return models.ClassFilter{CompanyID: id} })
+}
+
+// CourseFeed handles the calendar feed of a course.
+// @Summary Course calendar feed
+// @Description Subscribable iCalendar feed of the classes of a course
+// @Produce text/calendar
+// @Param id path uint true "Course ID"
+// @Success 200 {string} string
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Calendars
+// @Router /calendars/courses/{id}.ics [get]
+func (c *CalendarController) CourseFeed(ctx *fiber.Ctx) error {
+	return c.feed(ctx, "course", func(id uint) models.ClassFilter { return models.ClassFilter{CourseID: id} })
+}
+
+// InstructorFeed handles the calendar feed of an instructor.
+// @Summary Instructor calendar feed
+// @Description Subscribable iCalendar feed of the classes of an instructor
+// @Produce text/calendar
+// @Param id path uint true "Instructor ID"
+// @Success 200 {string} string
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Calendars
+// @Router /calendars/instructors/{id}.ics [get]
+func (c *CalendarController) InstructorFeed(ctx *fiber.Ctx) error {
+	return c.feed(ctx, "instructor", func(id uint) models.ClassFilter { return models.ClassFilter{InstructorID: id} })
+}
+
+// LearnerFeed handles the calendar feed of a learner.
+// @Summary Learner calendar feed
+// @Description Subscribable iCalendar feed of the classes a learner enrolled in, authenticated by the learner's calendar token
+// @Produce text/calendar
+// @Param id path uint true "Learner ID"
+// @Param token query string true "Calendar token"
+// @Success 200 {string} string
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Calendars
+// @Router /calendars/learners/{id}.ics [get]
+func (c *CalendarController) LearnerFeed(ctx *fiber.Ctx) error {
+	learnerID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid learner ID"})
+	}
+
+	calendar, err := c.calendarService.LearnerCalendar(uint(learnerID), ctx.Query("token"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Unknown and foreign tokens look alike.
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Calendar not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to build calendar"})
+	}
+
+	return sendCalendar(ctx, calendar)
+}
+
+// IssueLearnerToken handles issuing the calendar token of a learner.
+// @Summary Issue a learner calendar token
+// @Description Issue a new calendar token for a learner, revoking the previous one, and return the feed URL.
+// @Produce json
+// @Param id path uint true "Learner ID"
+// @Success 201 {object} object
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/learners/{id}/calendar-token [post]
+func (c *CalendarController) IssueLearnerToken(ctx *fiber.Ctx) error {
+	learnerID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid learner ID"})
+	}
+
+	token, err := c.calendarService.IssueLearnerToken(uint(learnerID))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not issue calendar token"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token": token.Token,
+		"url":   fmt.Sprintf("%s/calendars/learners/%d.ics?token=%s", ctx.BaseURL(), learnerID, token.Token),
+	})
+}
+
+func (c *CalendarController) feed(ctx *fiber.Ctx, owner string, filter func(uint) models.ClassFilter) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid %s ID", owner)})
+	}
+
+	calendar, err := c.calendarService.FeedCalendar(fmt.Sprintf("Classes of %s %d", owner, id), filter(uint(id)))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to build calendar"})
+	}
+
+	return sendCalendar(ctx, calendar)
+}
+
+func sendCalendar(ctx *fiber.Ctx, calendar *ical.Calendar) error {
+	ctx.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return ctx.Send(calendar.Encode())
+}
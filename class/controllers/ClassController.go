@@ -4,80 +4,172 @@ import (
 	"class/config"
 	"class/models"
 	"class/services"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"leecho/events"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type ClassController struct {
 	classService   *services.ClassService
 	rabbitMQConfig *config.RabbitMQConfig
+	writeMode      config.WriteMode
 }
 
-func NewClassController(classService *services.ClassService, rabbitMQConfig *config.RabbitMQConfig) *ClassController {
+func NewClassController(classService *services.ClassService, rabbitMQConfig *config.RabbitMQConfig, writeMode config.WriteMode) *ClassController {
 	return &ClassController{
 		classService:   classService,
 		rabbitMQConfig: rabbitMQConfig,
+		writeMode:      writeMode,
 	}
 }
 
-// ListClasses handles fetching all classes.
-// @Summary List all classes
-// @Description Retrieve a list of all classes
+// ListClasses handles fetching classes.
+// @Summary List classes
+// @Description Retrieve a page of classes, optionally filtered by company, course, instructor, class type and schedule range
 // @Produce json
-// @Success 200 {array} models.Class
+// @Param company_id query uint false "Company ID"
+// @Param course_id query uint false "Course ID"
+// @Param instructor_id query uint false "Instructor ID"
+// @Param class_type_id query uint false "Class type ID"
+// @Param scheduled_from query string false "Earliest schedule (RFC 3339), inclusive"
+// @Param scheduled_to query string false "Latest schedule (RFC 3339), exclusive"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
 // @Failure 500 {object} object
 // @Tags Classes
 // @Router /classes [get]
 func (c *ClassController) ListClasses(ctx *fiber.Ctx) error {
-	classes, err := c.classService.GetAllClasses()
+	filter, err := parseClassFilter(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	classes, nextCursor, err := c.classService.GetAllClasses(filter, ctx.Query("cursor"), ctx.QueryInt("limit"))
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+	}
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch classes"})
 	}
 
-	return ctx.JSON(classes)
+	return ctx.JSON(fiber.Map{"data": classes, "next_cursor": nextCursor})
+}
+
+func parseClassFilter(ctx *fiber.Ctx) (models.ClassFilter, error) {
+	var filter models.ClassFilter
+
+	ids := map[string]*uint{
+		"company_id":    &filter.CompanyID,
+		"course_id":     &filter.CourseID,
+		"instructor_id": &filter.InstructorID,
+		"class_type_id": &filter.ClassTypeID,
+	}
+	for key, target := range ids {
+		value := ctx.Query(key)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s", key)
+		}
+		*target = uint(id)
+	}
+
+	dates := map[string]**time.Time{
+		"scheduled_from": &filter.ScheduledFrom,
+		"scheduled_to":   &filter.ScheduledTo,
+	}
+	for key, target := range dates {
+		value := ctx.Query(key)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s, expected RFC 3339", key)
+		}
+		*target = &date
+	}
+
+	return filter, nil
 }
 
 // CreateClass handles the creation of a class.
 // @Summary Create a class
-// @Description Create a new class
+// @Description Create a new class, starting at local_start in time_zone or at scheduled_at. Its course and instructor must be known from the events of the course service. In synchronous write mode the persisted class is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param class body models.Class true "Class"
 // @Success 201 {object} models.Class
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 409 {object} object
 // @Failure 500 {object} object
 // @Tags Classes
-// @Router /classes [post]
+// @Router /class [post]
 func (c *ClassController) CreateClass(ctx *fiber.Ctx) error {
 	var class models.Class
 	if err := ctx.BodyParser(&class); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
-	classJSON, err := json.Marshal(class)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize class"})
+	correlationID := ctx.Get(events.CorrelationHeader)
+
+	if c.writeMode == config.WriteModeSync {
+		err := c.classService.CreateClass(&class, correlationID)
+		if unknownReference(err) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, models.ErrClassTypeUnavailable) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+		}
+		if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		var conflict *models.ConflictError
+		if errors.As(err, &conflict) {
+			return conflictResponse(ctx, conflict)
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create class"})
+		}
+		return ctx.Status(fiber.StatusCreated).JSON(class)
+	}
+
+	operation, err := c.classService.RequestClassCreation(class, correlationID)
+	if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) || unknownReference(err) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	if err := c.rabbitMQConfig.PublishMessage("class_created", classJSON); err != nil {
+	if errors.Is(err, models.ErrClassTypeUnavailable) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+	}
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create class"})
 	}
-
-	return ctx.Status(fiber.StatusCreated).JSON(class)
+	return acceptOperation(ctx, operation)
 }
 
 // UpdateClass handles the update of an existing class.
 // @Summary Update a class
-// @Description Update an existing class
+// @Description Update an existing class. In synchronous write mode the persisted class is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param class body models.Class true "Class"
 // @Success 200 {object} models.Class
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
 // @Failure 404 {object} object
+// @Failure 409 {object} object
 // @Failure 500 {object} object
 // @Tags Classes
-// @Router /classes [put]
+// @Router /class/{id} [put]
 func (c *ClassController) UpdateClass(ctx *fiber.Ctx) error {
 	var class models.Class
 	if err := ctx.BodyParser(&class); err != nil {
@@ -86,49 +178,220 @@ func (c *ClassController) UpdateClass(ctx *fiber.Ctx) error {
 	if class.ID == 0 {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Class ID is required"})
 	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	classJSON, err := json.Marshal(class)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize class"})
+	if c.writeMode == config.WriteModeSync {
+		err := c.classService.UpdateClass(class.ID, &class, correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+		}
+		if errors.Is(err, models.ErrClassTypeUnavailable) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+		}
+		if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) || unknownReference(err) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		var conflict *models.ConflictError
+		if errors.As(err, &conflict) {
+			return conflictResponse(ctx, conflict)
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update class"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(class)
+	}
+
+	operation, err := c.classService.RequestClassUpdate(class, correlationID)
+	if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) || unknownReference(err) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	if err := c.rabbitMQConfig.PublishMessage("class_updated", classJSON); err != nil {
+	if errors.Is(err, models.ErrClassTypeUnavailable) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+	}
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update class"})
 	}
-
-	return ctx.Status(fiber.StatusOK).JSON(class)
+	return acceptOperation(ctx, operation)
 }
 
 // DeleteClass handles the deletion of a class.
 // @Summary Delete a class
-// @Description Delete a class by ID
+// @Description Delete a class by ID. In asynchronous write mode an operation to poll is returned.
 // @Accept json
 // @Produce json
 // @Param id path uint true "Class ID"
 // @Success 200 {object} object
+// @Success 202 {object} models.Operation
 // @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Tags Classes
-// @Router /classes/{id} [delete]
+// @Router /class/{id} [delete]
 func (c *ClassController) DeleteClass(ctx *fiber.Ctx) error {
 	classID, err := strconv.Atoi(ctx.Params("id"))
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
 	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	classEvent := map[string]interface{}{
-		"event_type":   "class.deleted",
-		"service_name": "class_service",
-		"id":           classID,
+	if c.writeMode == config.WriteModeSync {
+		err := c.classService.DeleteClass(uint(classID), correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete class"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Class deleted successfully", "id": classID})
 	}
 
-	classJSON, err := json.Marshal(classEvent)
+	operation, err := c.classService.RequestClassDeletion(uint(classID), correlationID)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize class ID"})
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete class"})
 	}
+	return acceptOperation(ctx, operation)
+}
 
-	if err := c.rabbitMQConfig.PublishMessage("class_deleted", classJSON); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete class"})
+// CancelClass handles the cancellation of a class.
+// @Summary Cancel a class
+// @Description Cancel a class, keeping it in its history, and release its enrollments and waitlist places
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param cancellation body object true "Reason, as {\"reason\": \"Instructor unavailable\"}"
+// @Success 200 {object} services.ClassCancellation
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Classes
+// @Router /class/{id}/cancel [post]
+func (c *ClassController) CancelClass(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		Reason string `json:"reason"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.Reason == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reason is required"})
+	}
+
+	cancellation, err := c.classService.CancelClass(uint(classID), requestBody.Reason, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if errors.Is(err, services.ErrClassCancelled) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is already cancelled"})
 	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not cancel class"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(cancellation)
+}
+
+// RescheduleClass handles moving a class to a new slot.
+// @Summary Reschedule a class
+// @Description Move a class to a new start, given as local_start in time_zone or as scheduled_at, and optionally a new duration. Learners move with the class unless they are enrolled in another class at the new time, or release_enrollments is set; released seats go to the waitlist.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param rescheduling body object true "New slot and reason, as {\"scheduled_at\": \"2025-01-02T10:00:00Z\", \"duration\": 60, \"reason\": \"Room unavailable\", \"release_enrollments\": false}"
+// @Success 200 {object} services.ClassRescheduling
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Classes
+// @Router /class/{id}/reschedule [post]
+func (c *ClassController) RescheduleClass(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		ScheduledAt        time.Time `json:"scheduled_at"`
+		LocalStart         string    `json:"local_start"`
+		TimeZone           string    `json:"time_zone"`
+		Duration           uint      `json:"duration"`
+		Reason             string    `json:"reason"`
+		ReleaseEnrollments bool      `json:"release_enrollments"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.ScheduledAt.IsZero() && requestBody.LocalStart == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "scheduled_at or local_start is required"})
+	}
+	if requestBody.Reason == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reason is required"})
+	}
+
+	slot := models.Class{
+		ScheduledAt: requestBody.ScheduledAt,
+		LocalStart:  requestBody.LocalStart,
+		TimeZone:    requestBody.TimeZone,
+		Duration:    requestBody.Duration,
+	}
+	rescheduling, err := c.classService.RescheduleClass(uint(classID), slot, requestBody.Reason, requestBody.ReleaseEnrollments, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if errors.Is(err, services.ErrClassCancelled) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is cancelled"})
+	}
+	if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		return conflictResponse(ctx, conflict)
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reschedule class"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(rescheduling)
+}
+
+// GetClassHistory handles fetching the history of a class.
+// @Summary Get the history of a class
+// @Description Retrieve the cancellations and reschedulings of a class, oldest first
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Success 200 {array} models.ClassChange
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Classes
+// @Router /class/{id}/history [get]
+func (c *ClassController) GetClassHistory(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	history, err := c.classService.GetClassHistory(uint(classID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch class history"})
+	}
+
+	return ctx.JSON(history)
+}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Class deleted successfully", "id": classID})
+// conflictResponse answers 409 with the classes a class would overlap.
+func conflictResponse(ctx *fiber.Ctx, conflict *models.ConflictError) error {
+	return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":     "Class overlaps other classes of the same instructor, room or stream",
+		"conflicts": conflict.Conflicts,
+	})
 }
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"class/models"
+	"class/services"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ClassTypeController struct {
+	classTypeService *services.ClassTypeService
+}
+
+func NewClassTypeController(classTypeService *services.ClassTypeService) *ClassTypeController {
+	return &ClassTypeController{
+		classTypeService: classTypeService,
+	}
+}
+
+// ListClassTypes handles listing the class types of a company.
+// @Summary List class types
+// @Description Retrieve the default class types and the company's own
+// @Produce json
+// @Param company_id query uint true "Company ID"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags ClassTypes
+// @Router /class-types [get]
+func (c *ClassTypeController) ListClassTypes(ctx *fiber.Ctx) error {
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 64)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid company_id"})
+	}
+
+	classTypes, err := c.classTypeService.GetClassTypes(uint(companyID))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch class types"})
+	}
+
+	return ctx.JSON(fiber.Map{"data": classTypes})
+}
+
+// GetClassType handles fetching a class type.
+// @Summary Get a class type
+// @Description Retrieve a class type by ID
+// @Produce json
+// @Param id path uint true "Class type ID"
+// @Success 200 {object} models.ClassType
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Tags ClassTypes
+// @Router /class-type/{id} [get]
+func (c *ClassTypeController) GetClassType(ctx *fiber.Ctx) error {
+	classTypeID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type ID"})
+	}
+
+	classType, err := c.classTypeService.GetClassType(uint(classTypeID))
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class type not found"})
+	}
+
+	return ctx.JSON(classType)
+}
+
+// CreateClassType handles the creation of a company's class type.
+// @Summary Create a class type
+// @Description Create a class type for a company, with the defaults applied to its classes
+// @Accept json
+// @Produce json
+// @Param classType body models.ClassType true "ClassType"
+// @Success 201 {object} models.ClassType
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags ClassTypes
+// @Router /class-type [post]
+func (c *ClassTypeController) CreateClassType(ctx *fiber.Ctx) error {
+	var classType models.ClassType
+	if err := ctx.BodyParser(&classType); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if classType.CompanyID == nil || *classType.CompanyID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Company ID is required"})
+	}
+	if classType.Name == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+
+	classType.ID = 0
+	if err := c.classTypeService.CreateClassType(&classType); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create class type"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(classType)
+}
+
+// UpdateClassType handles the update of a company's class type.
+// @Summary Update a class type
+// @Description Replace the name, description and defaults of a company's class type. Default class types cannot be changed.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class type ID"
+// @Param classType body models.ClassType true "ClassType"
+// @Success 200 {object} models.ClassType
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags ClassTypes
+// @Router /class-type/{id} [put]
+func (c *ClassTypeController) UpdateClassType(ctx *fiber.Ctx) error {
+	classTypeID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type ID"})
+	}
+
+	var classType models.ClassType
+	if err := ctx.BodyParser(&classType); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if classType.Name == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+
+	err = c.classTypeService.UpdateClassType(uint(classTypeID), &classType)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class type not found"})
+	case errors.Is(err, services.ErrDefaultClassType):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Default class types cannot be changed"})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update class type"})
+	}
+
+	return ctx.JSON(classType)
+}
+
+// DeleteClassType handles the deletion of a company's class type.
+// @Summary Delete a class type
+// @Description Delete a company's class type. When classes or series still use it, reassign_to must name another type of the company or a default type to move them to.
+// @Produce json
+// @Param id path uint true "Class type ID"
+// @Param reassign_to query uint false "Class type to move the classes and series of the deleted type to"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags ClassTypes
+// @Router /class-type/{id} [delete]
+func (c *ClassTypeController) DeleteClassType(ctx *fiber.Ctx) error {
+	classTypeID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type ID"})
+	}
+	reassignTo := ctx.QueryInt("reassign_to")
+	if reassignTo < 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid reassign_to"})
+	}
+
+	err = c.classTypeService.DeleteClassType(uint(classTypeID), uint(reassignTo))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class type not found"})
+	case errors.Is(err, services.ErrDefaultClassType):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Default class types cannot be deleted"})
+	case errors.Is(err, services.ErrClassTypeInUse):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class type is still used by classes or series, pass reassign_to to move them"})
+	case errors.Is(err, services.ErrInvalidReassignee):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid reassign_to"})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete class type"})
+	}
+
+	return ctx.JSON(fiber.Map{"message": "Class type deleted successfully", "id": classTypeID})
+}
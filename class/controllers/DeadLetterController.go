@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"class/config"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const deadLetterScanLimit = 1000
+
+type DeadLetterController struct {
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewDeadLetterController(rabbitMQConfig *config.RabbitMQConfig) *DeadLetterController {
+	return &DeadLetterController{
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// ListDeadLetters handles listing the dead letters of a queue.
+// @Summary List dead letters
+// @Description Retrieve the messages a consumer gave up on, without removing them
+// @Produce json
+// @Param queue path string true "Queue name"
+// @Param limit query int false "Maximum number of messages (default 100)"
+// @Success 200 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/dead-letters/{queue} [get]
+func (c *DeadLetterController) ListDeadLetters(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", 100)
+	if limit <= 0 || limit > deadLetterScanLimit {
+		limit = deadLetterScanLimit
+	}
+
+	deadLetters, err := c.rabbitMQConfig.ListDeadLetters(ctx.Params("queue"), limit)
+	if err != nil {
+		return deadLetterError(ctx, err, "Unable to list dead letters")
+	}
+
+	return ctx.JSON(fiber.Map{"data": deadLetters})
+}
+
+// GetDeadLetter handles inspecting one dead letter.
+// @Summary Inspect a dead letter
+// @Description Retrieve a dead-lettered message by event ID
+// @Produce json
+// @Param queue path string true "Queue name"
+// @Param eventId path string true "Event ID"
+// @Success 200 {object} config.DeadLetter
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/dead-letters/{queue}/{eventId} [get]
+func (c *DeadLetterController) GetDeadLetter(ctx *fiber.Ctx) error {
+	deadLetter, err := c.rabbitMQConfig.GetDeadLetter(ctx.Params("queue"), ctx.Params("eventId"), deadLetterScanLimit)
+	if err != nil {
+		return deadLetterError(ctx, err, "Unable to fetch dead letter")
+	}
+
+	return ctx.JSON(deadLetter)
+}
+
+// ReplayDeadLetters handles sending dead letters back to their queue.
+// @Summary Replay dead letters
+// @Description Publish dead letters back to their queue, all of them or only the one with the given event ID
+// @Produce json
+// @Param queue path string true "Queue name"
+// @Param event_id query string false "Event ID"
+// @Success 200 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/dead-letters/{queue}/replay [post]
+func (c *DeadLetterController) ReplayDeadLetters(ctx *fiber.Ctx) error {
+	replayed, err := c.rabbitMQConfig.ReplayDeadLetters(ctx.Params("queue"), ctx.Query("event_id"), deadLetterScanLimit)
+	if err != nil {
+		return deadLetterError(ctx, err, "Unable to replay dead letters")
+	}
+	if replayed == 0 && ctx.Query("event_id") != "" {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Dead letter not found"})
+	}
+
+	return ctx.JSON(fiber.Map{"message": "Dead letters replayed successfully", "replayed": replayed})
+}
+
+// PurgeDeadLetters handles dropping dead letters.
+// @Summary Purge dead letters
+// @Description Drop the dead letters of a queue, all of them or only the one with the given event ID
+// @Produce json
+// @Param queue path string true "Queue name"
+// @Param event_id query string false "Event ID"
+// @Success 200 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/dead-letters/{queue} [delete]
+func (c *DeadLetterController) PurgeDeadLetters(ctx *fiber.Ctx) error {
+	purged, err := c.rabbitMQConfig.PurgeDeadLetters(ctx.Params("queue"), ctx.Query("event_id"), deadLetterScanLimit)
+	if err != nil {
+		return deadLetterError(ctx, err, "Unable to purge dead letters")
+	}
+	if purged == 0 && ctx.Query("event_id") != "" {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Dead letter not found"})
+	}
+
+	return ctx.JSON(fiber.Map{"message": "Dead letters purged successfully", "purged": purged})
+}
+
+func deadLetterError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, config.ErrUnknownQueue):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown queue"})
+	case errors.Is(err, config.ErrDeadLetterNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Dead letter not found"})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
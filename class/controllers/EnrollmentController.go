@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"class/services"
+	"errors"
+	"leecho/events"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type EnrollmentController struct {
+	enrollmentService *services.EnrollmentService
+}
+
+func NewEnrollmentController(enrollmentService *services.EnrollmentService) *EnrollmentController {
+	return &EnrollmentController{
+		enrollmentService: enrollmentService,
+	}
+}
+
+// Enroll handles enrolling a learner in a class.
+// @Summary Enroll a learner
+// @Description Give a learner a seat in a class, or a place on its waitlist when the class is full and has one
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param enrollment body object true "Learner, as {\"learner_id\": 1}"
+// @Success 201 {object} models.Enrollment
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Enrollments
+// @Router /class/{id}/enrollments [post]
+func (c *EnrollmentController) Enroll(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		LearnerID uint `json:"learner_id"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.LearnerID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Learner ID is required"})
+	}
+
+	enrollment, err := c.enrollmentService.Enroll(uint(classID), requestBody.LearnerID, ctx.Get(events.CorrelationHeader))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	case errors.Is(err, services.ErrAlreadyEnrolled):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Learner is already enrolled or waitlisted"})
+	case errors.Is(err, services.ErrClassCancelled):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is cancelled"})
+	case errors.Is(err, services.ErrClassFull):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is full"})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not enroll learner"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(enrollment)
+}
+
+// CancelEnrollment handles removing a learner from a class.
+// @Summary Cancel an enrollment
+// @Description Remove a learner from a class or its waitlist. A freed seat goes to the learner waiting longest.
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param learnerId path uint true "Learner ID"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Enrollments
+// @Router /class/{id}/enrollments/{learnerId} [delete]
+func (c *EnrollmentController) CancelEnrollment(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+	learnerID, err := strconv.Atoi(ctx.Params("learnerId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid learner ID"})
+	}
+
+	err = c.enrollmentService.CancelEnrollment(uint(classID), uint(learnerID), ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Enrollment not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not cancel enrollment"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Enrollment cancelled successfully", "class_id": classID, "learner_id": learnerID})
+}
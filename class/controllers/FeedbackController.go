@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"class/models"
+	"class/services"
+	"errors"
+	"fmt"
+	"leecho/events"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type FeedbackController struct {
+	feedbackService *services.FeedbackService
+}
+
+func NewFeedbackController(feedbackService *services.FeedbackService) *FeedbackController {
+	return &FeedbackController{
+		feedbackService: feedbackService,
+	}
+}
+
+// SubmitFeedback handles a learner rating a class.
+// @Summary Submit feedback
+// @Description Rate a class the learner attended from 1 to 5, with an optional comment and an optional rating of its instructor. Feedback is submitted once per class.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param feedback body object true "Feedback, as {\"learner_id\": 1, \"rating\": 5, \"comment\": \"...\", \"instructor_rating\": 4, \"instructor_comment\": \"...\"}"
+// @Success 201 {object} models.Feedback
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /class/{id}/feedback [post]
+func (c *FeedbackController) SubmitFeedback(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		LearnerID         uint   `json:"learner_id"`
+		Rating            uint   `json:"rating"`
+		Comment           string `json:"comment"`
+		InstructorRating  *uint  `json:"instructor_rating"`
+		InstructorComment string `json:"instructor_comment"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.LearnerID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Learner ID is required"})
+	}
+
+	feedback := models.Feedback{
+		LearnerID:         requestBody.LearnerID,
+		Rating:            requestBody.Rating,
+		Comment:           requestBody.Comment,
+		InstructorRating:  requestBody.InstructorRating,
+		InstructorComment: requestBody.InstructorComment,
+	}
+	err = c.feedbackService.SubmitFeedback(uint(classID), &feedback, ctx.Get(events.CorrelationHeader))
+	switch {
+	case errors.Is(err, services.ErrInvalidRating):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Ratings must be between 1 and 5"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	case errors.Is(err, services.ErrNotAttended):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only learners who attended the class may rate it"})
+	case errors.Is(err, services.ErrFeedbackSubmitted):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Feedback already submitted for this class"})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not submit feedback"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(feedback)
+}
+
+// ListClassFeedback handles fetching the feedback of a class.
+// @Summary List the feedback of a class
+// @Description Retrieve the feedback of a class not hidden by moderation, newest first
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Success 200 {array} models.Feedback
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /class/{id}/feedback [get]
+func (c *FeedbackController) ListClassFeedback(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	feedback, err := c.feedbackService.GetClassFeedback(uint(classID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch feedback"})
+	}
+
+	return ctx.JSON(feedback)
+}
+
+// GetFeedbackSummary handles aggregating feedback.
+// @Summary Summarize feedback
+// @Description Average, distribution and NPS of the ratings given to classes and to their instructors, for a class, course, class type or instructor. NPS counts 5 as a promoter and 1 to 3 as detractors. Hidden feedback is left out.
+// @Produce json
+// @Param class_id query uint false "Class ID"
+// @Param course_id query uint false "Course ID"
+// @Param class_type_id query uint false "Class type ID"
+// @Param instructor_id query uint false "Instructor ID"
+// @Success 200 {object} services.FeedbackSummary
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /feedback/summary [get]
+func (c *FeedbackController) GetFeedbackSummary(ctx *fiber.Ctx) error {
+	var filter models.FeedbackFilter
+	ids := map[string]*uint{
+		"class_id":      &filter.ClassID,
+		"course_id":     &filter.CourseID,
+		"class_type_id": &filter.ClassTypeID,
+		"instructor_id": &filter.InstructorID,
+	}
+	for key, target := range ids {
+		value := ctx.Query(key)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid %s", key)})
+		}
+		*target = uint(id)
+	}
+
+	summary, err := c.feedbackService.Summarize(filter)
+	if errors.Is(err, services.ErrMissingFeedbackScope) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "One of class_id, course_id, class_type_id or instructor_id is required"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to summarize feedback"})
+	}
+
+	return ctx.JSON(summary)
+}
+
+// FlagFeedback handles reporting feedback to moderators.
+// @Summary Flag feedback
+// @Description Flag feedback for moderation, with a reason. Flagged feedback still counts until a moderator hides it.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Feedback ID"
+// @Param flag body object true "Reason, as {\"reason\": \"Offensive language\"}"
+// @Success 200 {object} models.Feedback
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /feedback/{id}/flag [post]
+func (c *FeedbackController) FlagFeedback(ctx *fiber.Ctx) error {
+	feedbackID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid feedback ID"})
+	}
+
+	var requestBody struct {
+		Reason string `json:"reason"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.Reason == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reason is required"})
+	}
+
+	feedback, err := c.feedbackService.FlagFeedback(uint(feedbackID), requestBody.Reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Feedback not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not flag feedback"})
+	}
+
+	return ctx.JSON(feedback)
+}
+
+// ModerateFeedback handles a moderator's decision on feedback.
+// @Summary Moderate feedback
+// @Description Show or hide feedback, settling any flag. Hidden feedback is left out of listings and summaries.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Feedback ID"
+// @Param moderation body object true "Decision, as {\"status\": \"visible\"} or {\"status\": \"hidden\"}"
+// @Success 200 {object} models.Feedback
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /feedback/{id}/moderation [put]
+func (c *FeedbackController) ModerateFeedback(ctx *fiber.Ctx) error {
+	feedbackID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid feedback ID"})
+	}
+
+	var requestBody struct {
+		Status string `json:"status"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	feedback, err := c.feedbackService.ModerateFeedback(uint(feedbackID), requestBody.Status)
+	if errors.Is(err, services.ErrInvalidModeration) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Status must be visible or hidden"})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Feedback not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not moderate feedback"})
+	}
+
+	return ctx.JSON(feedback)
+}
+
+// ListFlaggedFeedback handles fetching the moderation queue.
+// @Summary List flagged feedback
+// @Description Retrieve the feedback awaiting moderation, oldest flag first
+// @Produce json
+// @Success 200 {array} models.Feedback
+// @Failure 500 {object} object
+// @Tags Feedback
+// @Router /feedback/flagged [get]
+func (c *FeedbackController) ListFlaggedFeedback(ctx *fiber.Ctx) error {
+	feedback, err := c.feedbackService.GetFlaggedFeedback()
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch flagged feedback"})
+	}
+
+	return ctx.JSON(feedback)
+}
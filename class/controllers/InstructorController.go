@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"class/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InstructorController struct {
+	classService *services.ClassService
+}
+
+func NewInstructorController(classService *services.ClassService) *InstructorController {
+	return &InstructorController{
+		classService: classService,
+	}
+}
+
+// GetAvailability handles computing the schedule of an instructor.
+// @Summary Get instructor availability
+// @Description Retrieve the busy and free blocks of an instructor between two instants, computed from their classes (at most 92 days)
+// @Produce json
+// @Param id path uint true "Instructor ID"
+// @Param from query string true "Start (RFC 3339), inclusive"
+// @Param to query string true "End (RFC 3339), exclusive"
+// @Success 200 {object} services.Availability
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags Instructors
+// @Router /instructors/{id}/availability [get]
+func (c *InstructorController) GetAvailability(ctx *fiber.Ctx) error {
+	instructorID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid instructor ID"})
+	}
+	from, err := time.Parse(time.RFC3339, ctx.Query("from"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from, expected RFC 3339"})
+	}
+	to, err := time.Parse(time.RFC3339, ctx.Query("to"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to, expected RFC 3339"})
+	}
+
+	availability, err := c.classService.GetInstructorAvailability(uint(instructorID), from, to)
+	if errors.Is(err, services.ErrInvalidRange) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must precede to by at most 92 days"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to compute availability"})
+	}
+
+	return ctx.JSON(availability)
+}
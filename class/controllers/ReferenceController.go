@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"class/models"
+	"class/services"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReferenceController struct {
+	referenceService *services.ReferenceService
+}
+
+func NewReferenceController(referenceService *services.ReferenceService) *ReferenceController {
+	return &ReferenceController{
+		referenceService: referenceService,
+	}
+}
+
+// RebuildReferences handles re-reading the courses and instructors classes may point at.
+// @Summary Rebuild the known courses and instructors
+// @Description Replace the courses and instructors known from course events with those the course service lists, to recover from missed events
+// @Produce json
+// @Success 200 {object} object
+// @Failure 502 {object} object
+// @Tags Admin
+// @Router /admin/projections/courses/rebuild [post]
+func (c *ReferenceController) RebuildReferences(ctx *fiber.Ctx) error {
+	known, err := c.referenceService.Rebuild()
+	if err != nil {
+		return ctx.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Could not rebuild the known courses"})
+	}
+
+	return ctx.JSON(fiber.Map{"courses": known})
+}
+
+// unknownReference reports whether err rejects a course or instructor the class service does not
+// know of.
+func unknownReference(err error) bool {
+	return errors.Is(err, models.ErrUnknownCourse) || errors.Is(err, models.ErrUnknownInstructor)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"class/services"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type RoomController struct {
+	roomService *services.RoomService
+}
+
+func NewRoomController(roomService *services.RoomService) *RoomController {
+	return &RoomController{
+		roomService: roomService,
+	}
+}
+
+// GetRoom handles fetching the virtual room of a class for its instructor.
+// @Summary Get the virtual room of a class
+// @Description Retrieve the virtual room of an online class, as its instructor. The host link is only served against a host token.
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param instructor_id query uint true "Instructor ID"
+// @Success 200 {object} models.VirtualRoom
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 502 {object} object
+// @Tags Rooms
+// @Router /class/{id}/room [get]
+func (c *RoomController) GetRoom(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+	instructorID := ctx.QueryInt("instructor_id")
+	if instructorID <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Instructor ID is required"})
+	}
+
+	room, err := c.roomService.GetRoom(uint(classID), uint(instructorID))
+	if err != nil {
+		return roomError(ctx, err)
+	}
+
+	return ctx.JSON(room)
+}
+
+// HostRoom handles revealing the host link of a class to its instructor.
+// @Summary Host the virtual room of a class
+// @Description Retrieve the host link and dial-in of an online class, authenticated by the host token issued to its instructor
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param token query string true "Host token"
+// @Success 200 {object} services.HostLink
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 502 {object} object
+// @Tags Rooms
+// @Router /class/{id}/room/host [get]
+func (c *RoomController) HostRoom(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	link, err := c.roomService.HostRoom(uint(classID), ctx.Query("token"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Unknown and foreign tokens look alike.
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Room not found"})
+	}
+	if err != nil {
+		return roomError(ctx, err)
+	}
+
+	return ctx.JSON(link)
+}
+
+// IssueHostToken handles issuing the host token of the room of a class.
+// @Summary Issue a room host token
+// @Description Issue a new host token for the instructor of an online class, revoking the previous one, and return the host link URL.
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Success 201 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Admin
+// @Router /admin/classes/{id}/room/host-token [post]
+func (c *RoomController) IssueHostToken(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	token, err := c.roomService.IssueHostToken(uint(classID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	}
+	if errors.Is(err, services.ErrClassNotOnline) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is not held online"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not issue host token"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token": token.Token,
+		"url":   fmt.Sprintf("%s/class/%d/room/host?token=%s", ctx.BaseURL(), classID, token.Token),
+	})
+}
+
+// JoinRoom handles revealing the join link of a class to a learner.
+// @Summary Join the virtual room of a class
+// @Description Retrieve the personal join link, dial-in and recording of an online class, for a learner holding a seat in it
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param learner_id query uint true "Learner ID"
+// @Success 200 {object} services.JoinLink
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 502 {object} object
+// @Tags Rooms
+// @Router /class/{id}/room/join [get]
+func (c *RoomController) JoinRoom(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+	learnerID := ctx.QueryInt("learner_id")
+	if learnerID <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Learner ID is required"})
+	}
+
+	link, err := c.roomService.JoinRoom(uint(classID), uint(learnerID))
+	if errors.Is(err, services.ErrNotEnrolled) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Learner is not enrolled in this class"})
+	}
+	if err != nil {
+		return roomError(ctx, err)
+	}
+
+	return ctx.JSON(link)
+}
+
+// SetRecording handles attaching the recording of a class.
+// @Summary Set the recording of a class
+// @Description Store the link to the recording of an online class, as its instructor. Enrolled learners see it with their join link.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Class ID"
+// @Param recording body object true "Recording, as {\"instructor_id\": 1, \"recording_url\": \"https://...\"}"
+// @Success 200 {object} models.VirtualRoom
+// @Failure 400 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags Rooms
+// @Router /class/{id}/room/recording [put]
+func (c *RoomController) SetRecording(ctx *fiber.Ctx) error {
+	classID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	var requestBody struct {
+		InstructorID uint   `json:"instructor_id"`
+		RecordingURL string `json:"recording_url"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.InstructorID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Instructor ID is required"})
+	}
+	if recordingURL, err := url.ParseRequestURI(requestBody.RecordingURL); err != nil || recordingURL.Host == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid recording URL"})
+	}
+
+	room, err := c.roomService.SetRecording(uint(classID), requestBody.InstructorID, requestBody.RecordingURL)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class or room not found"})
+	}
+	if err != nil {
+		return roomError(ctx, err)
+	}
+
+	return ctx.JSON(room)
+}
+
+// roomError answers the errors shared by the virtual room endpoints. Other errors come from the
+// virtual room provider.
+func roomError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class not found"})
+	case errors.Is(err, services.ErrNotClassInstructor):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only the instructor of the class may do this"})
+	case errors.Is(err, services.ErrClassNotOnline):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is not held online"})
+	case errors.Is(err, services.ErrClassCancelled):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is cancelled"})
+	default:
+		return ctx.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Virtual room provider unavailable"})
+	}
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"class/models"
+	"class/recurrence"
+	"class/services"
+	"errors"
+	"leecho/events"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SeriesController struct {
+	seriesService *services.SeriesService
+}
+
+func NewSeriesController(seriesService *services.SeriesService) *SeriesController {
+	return &SeriesController{
+		seriesService: seriesService,
+	}
+}
+
+// CreateSeries handles the creation of a recurring class series.
+// @Summary Create a class series
+// @Description Create a class repeating by an RFC 5545 recurrence rule (FREQ DAILY, WEEKLY or MONTHLY with INTERVAL, COUNT, UNTIL and BYDAY). Its occurrences are materialised as classes within the configured horizon; those overlapping other classes are skipped and recorded as exception dates.
+// @Accept json
+// @Produce json
+// @Param series body models.ClassSeries true "ClassSeries"
+// @Success 201 {object} object
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Tags ClassSeries
+// @Router /class-series [post]
+func (c *SeriesController) CreateSeries(ctx *fiber.Ctx) error {
+	var series models.ClassSeries
+	if err := ctx.BodyParser(&series); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if (series.StartsAt.IsZero() && series.LocalStart == "") || series.RecurrenceRule == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "starts_at or local_start, and rrule are required"})
+	}
+
+	err := c.seriesService.CreateSeries(&series, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, recurrence.ErrInvalidRule) || errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) || unknownReference(err) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, models.ErrClassTypeUnavailable) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create class series"})
+	}
+
+	return c.respondWithSeries(ctx, fiber.StatusCreated, series.ID)
+}
+
+// GetSeries handles fetching a class series.
+// @Summary Get a class series
+// @Description Retrieve a class series with its materialised classes
+// @Produce json
+// @Param id path uint true "Series ID"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Tags ClassSeries
+// @Router /class-series/{id} [get]
+func (c *SeriesController) GetSeries(ctx *fiber.Ctx) error {
+	seriesID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid series ID"})
+	}
+
+	return c.respondWithSeries(ctx, fiber.StatusOK, uint(seriesID))
+}
+
+// DeleteSeries handles the deletion of a class series.
+// @Summary Delete a class series
+// @Description Delete a class series and every class materialised from it
+// @Produce json
+// @Param id path uint true "Series ID"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags ClassSeries
+// @Router /class-series/{id} [delete]
+func (c *SeriesController) DeleteSeries(ctx *fiber.Ctx) error {
+	seriesID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid series ID"})
+	}
+
+	err = c.seriesService.DeleteSeries(uint(seriesID), ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class series not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete class series"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Class series deleted successfully", "id": seriesID})
+}
+
+// UpdateOccurrence handles editing an occurrence of a class series.
+// @Summary Update an occurrence
+// @Description Edit one class of a series (scope=this) or the class and the following ones (scope=following), which splits the series. Only scope=this can reschedule.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Series ID"
+// @Param classId path uint true "Class ID"
+// @Param scope query string false "this (default) or following"
+// @Param class body models.Class true "Class"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Tags ClassSeries
+// @Router /class-series/{id}/occurrences/{classId} [put]
+func (c *SeriesController) UpdateOccurrence(ctx *fiber.Ctx) error {
+	seriesID, classID, scope, err := parseOccurrence(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var changes models.Class
+	if err := ctx.BodyParser(&changes); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	series, err := c.seriesService.UpdateOccurrence(seriesID, classID, scope, changes, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Occurrence not found"})
+	}
+	if errors.Is(err, models.ErrClassTypeUnavailable) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class type"})
+	}
+	if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocalTime) || unknownReference(err) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		return conflictResponse(ctx, conflict)
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update occurrence"})
+	}
+
+	return c.respondWithSeries(ctx, fiber.StatusOK, series.ID)
+}
+
+// DeleteOccurrence handles deleting an occurrence of a class series.
+// @Summary Delete an occurrence
+// @Description Delete one class of a series (scope=this), recorded as an exception date, or the class and the following ones (scope=following), which ends the series before it
+// @Produce json
+// @Param id path uint true "Series ID"
+// @Param classId path uint true "Class ID"
+// @Param scope query string false "this (default) or following"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags ClassSeries
+// @Router /class-series/{id}/occurrences/{classId} [delete]
+func (c *SeriesController) DeleteOccurrence(ctx *fiber.Ctx) error {
+	seriesID, classID, scope, err := parseOccurrence(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = c.seriesService.DeleteOccurrence(seriesID, classID, scope, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Occurrence not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete occurrence"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Occurrence deleted successfully", "id": classID, "scope": scope})
+}
+
+func (c *SeriesController) respondWithSeries(ctx *fiber.Ctx, status int, seriesID uint) error {
+	series, classes, err := c.seriesService.GetSeries(seriesID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Class series not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch class series"})
+	}
+
+	return ctx.Status(status).JSON(fiber.Map{"series": series, "occurrences": classes})
+}
+
+func parseOccurrence(ctx *fiber.Ctx) (uint, uint, services.EditScope, error) {
+	seriesID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return 0, 0, "", errors.New("Invalid series ID")
+	}
+	classID, err := strconv.Atoi(ctx.Params("classId"))
+	if err != nil {
+		return 0, 0, "", errors.New("Invalid class ID")
+	}
+
+	scope := services.EditScope(ctx.Query("scope", string(services.ScopeThis)))
+	if scope != services.ScopeThis && scope != services.ScopeFollowing {
+		return 0, 0, "", errors.New("Invalid scope, expected this or following")
+	}
+	return uint(seriesID), uint(classID), scope, nil
+}
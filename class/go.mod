@@ -10,6 +10,7 @@ require (
 	github.com/swaggo/swag v1.16.3
 	gorm.io/driver/postgres v1.5.9
 	gorm.io/gorm v1.25.12
+	leecho/events v0.0.0
 )
 
 require (
@@ -44,3 +45,5 @@ require (
 	golang.org/x/tools v0.26.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace leecho/events => ../events
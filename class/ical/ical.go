@@ -0,0 +1,237 @@
+// Package ical writes RFC 5545 calendars.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+const (
+	productID     = "-//Leecho//Classes//EN"
+	dateTimeUTC   = "20060102T150405Z"
+	dateTimeLocal = "20060102T150405"
+	maxLineOctets = 75
+)
+
+// Event is a VEVENT. UID must stay the same across updates of the event, with Sequence bumped on
+// every significant change. Start and End are written as local times in TimeZone, an IANA zone
+// described in a VTIMEZONE, or in UTC when it is empty or unknown.
+type Event struct {
+	UID          string
+	Sequence     uint
+	Start        time.Time
+	End          time.Time
+	TimeZone     string
+	Summary      string
+	Description  string
+	Location     string
+	Status       string
+	Created      time.Time
+	LastModified time.Time
+}
+
+// Calendar is a VCALENDAR publishing events.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Encode writes the calendar, with CRLF line endings and lines folded at 75 octets.
+func (c Calendar) Encode() []byte {
+	var buf bytes.Buffer
+	stamp := time.Now()
+
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:"+productID)
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+escapeText(c.Name))
+	}
+	for _, span := range c.zoneSpans() {
+		writeTimeZone(&buf, span)
+	}
+
+	for _, event := range c.Events {
+		location := eventLocation(event)
+		writeLine(&buf, "BEGIN:VEVENT")
+		writeLine(&buf, "UID:"+event.UID)
+		writeLine(&buf, "SEQUENCE:"+strconv.FormatUint(uint64(event.Sequence), 10))
+		writeLine(&buf, "DTSTAMP:"+formatUTC(stamp))
+		writeLine(&buf, formatDateTime("DTSTART", event.Start, location))
+		writeLine(&buf, formatDateTime("DTEND", event.End, location))
+		writeLine(&buf, "SUMMARY:"+escapeText(event.Summary))
+		if event.Description != "" {
+			writeLine(&buf, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.Location != "" {
+			writeLine(&buf, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.Status != "" {
+			writeLine(&buf, "STATUS:"+event.Status)
+		}
+		if !event.Created.IsZero() {
+			writeLine(&buf, "CREATED:"+formatUTC(event.Created))
+		}
+		if !event.LastModified.IsZero() {
+			writeLine(&buf, "LAST-MODIFIED:"+formatUTC(event.LastModified))
+		}
+		writeLine(&buf, "END:VEVENT")
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeUTC)
+}
+
+// formatDateTime formats a DATE-TIME property as a local time in location, or in UTC when location
+// is nil.
+func formatDateTime(property string, t time.Time, location *time.Location) string {
+	if location == nil {
+		return property + ":" + formatUTC(t)
+	}
+	return property + ";TZID=" + location.String() + ":" + t.In(location).Format(dateTimeLocal)
+}
+
+// eventLocation returns the zone the times of an event are written in, nil for UTC.
+func eventLocation(event Event) *time.Location {
+	if event.TimeZone == "" || event.TimeZone == "UTC" {
+		return nil
+	}
+	location, err := time.LoadLocation(event.TimeZone)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// zoneSpan is a zone used by the events of a calendar, with the time range they cover.
+type zoneSpan struct {
+	location *time.Location
+	from, to time.Time
+}
+
+// zoneSpans returns the zones the events are written in, in order of first use.
+func (c Calendar) zoneSpans() []zoneSpan {
+	var spans []zoneSpan
+	index := make(map[string]int)
+	for _, event := range c.Events {
+		location := eventLocation(event)
+		if location == nil {
+			continue
+		}
+		i, ok := index[location.String()]
+		if !ok {
+			index[location.String()] = len(spans)
+			spans = append(spans, zoneSpan{location: location, from: event.Start, to: event.End})
+			continue
+		}
+		if event.Start.Before(spans[i].from) {
+			spans[i].from = event.Start
+		}
+		if event.End.After(spans[i].to) {
+			spans[i].to = event.End
+		}
+	}
+	return spans
+}
+
+// writeTimeZone writes the VTIMEZONE of a zone, with an observance for the offset in effect at the
+// start of the span and one for every transition within it.
+func writeTimeZone(buf *bytes.Buffer, span zoneSpan) {
+	writeLine(buf, "BEGIN:VTIMEZONE")
+	writeLine(buf, "TZID:"+span.location.String())
+	at := span.from.In(span.location)
+	onset, next := at.ZoneBounds()
+	writeObservance(buf, onset, at)
+	for !next.IsZero() && !next.After(span.to) {
+		at = next
+		_, next = at.ZoneBounds()
+		writeObservance(buf, at, at)
+	}
+	writeLine(buf, "END:VTIMEZONE")
+}
+
+// writeObservance writes the STANDARD or DAYLIGHT observance in effect at at, which began at onset,
+// or since ever when onset is zero.
+func writeObservance(buf *bytes.Buffer, onset, at time.Time) {
+	name, offset := at.Zone()
+	start := "19700101T000000"
+	from := offset
+	if !onset.IsZero() {
+		_, from = onset.Add(-time.Second).Zone()
+		// The onset is given in the local time of the offset it ends.
+		start = onset.UTC().Add(time.Duration(from) * time.Second).Format(dateTimeLocal)
+	}
+	kind := "STANDARD"
+	if at.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	writeLine(buf, "BEGIN:"+kind)
+	writeLine(buf, "DTSTART:"+start)
+	writeLine(buf, "TZOFFSETFROM:"+formatOffset(from))
+	writeLine(buf, "TZOFFSETTO:"+formatOffset(offset))
+	writeLine(buf, "TZNAME:"+escapeText(name))
+	writeLine(buf, "END:"+kind)
+}
+
+// formatOffset formats a UTC offset in seconds as a UTC-OFFSET value.
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	offset := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		offset += fmt.Sprintf("%02d", seconds%60)
+	}
+	return offset
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// writeLine writes a content line, folding it so that no line exceeds 75 octets without splitting
+// a UTF-8 sequence.
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts toward their length.
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -4,7 +4,9 @@ import (
 	"class/config"
 	"class/consumers"
 	"class/models"
+	"class/publishers"
 	"class/routes"
+	"class/services"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -29,8 +31,8 @@ func main() {
 	}
 	defer rabbitMQConfig.Close()
 
-	if err := rabbitMQConfig.DeclareQueue("class_events", true); err != nil {
-		log.Fatalf("Failed to declare queue: %s", err)
+	if err := rabbitMQConfig.DeclareTopology(config.Topology); err != nil {
+		log.Fatalf("Failed to declare topology: %s", err)
 	}
 
 	db, err := config.ConnectDatabase()
@@ -38,19 +40,59 @@ func main() {
 		log.Fatalf("Failed to connect to database: %s", err)
 	}
 
-	if err := db.AutoMigrate(&models.Class{}); err != nil {
+	if err := db.AutoMigrate(&models.ClassType{}, &models.ClassSeries{}, &models.Class{}, &models.OutboxEvent{}, &models.ProcessedEvent{}, &models.Operation{}, &models.Enrollment{}, &models.CalendarToken{}, &models.RoomHostToken{}, &models.Attendance{}, &models.CheckInCode{}, &models.ClassChange{}, &models.VirtualRoom{}, &models.Feedback{}, &models.CourseReference{}, &models.InstructorReference{}); err != nil {
 		log.Fatalf("Failed to run migrations: %s", err)
 	}
 	if err := models.MigrateDefaultClassTypes(db); err != nil {
 		log.Fatalf("Failed to migrate default class types: %s", err)
 	}
+	if err := models.MigrateLocalTimes(db); err != nil {
+		log.Fatalf("Failed to migrate local times: %s", err)
+	}
+
+	retention, err := config.ProcessedEventsRetention()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	consumers.StartProcessedEventLedgerPruner(db, retention)
 
+	writeMode, err := config.GetWriteMode()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	seriesHorizon, err := config.ClassSeriesHorizon()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	roomProvider, err := config.VirtualRoomProvider()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	courseServiceURL, err := config.CourseServiceURL()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+
+	consumers.StartOperationTracker(rabbitMQConfig, db)
 	consumers.StartClassEventConsumer(rabbitMQConfig, db)
+	consumers.StartCourseReferenceConsumer(rabbitMQConfig, db)
+	if err := rabbitMQConfig.VerifyTopology(config.Topology); err != nil {
+		log.Fatalf("Invalid topology: %s", err)
+	}
+	publishers.StartOutboxPublisher(rabbitMQConfig, db)
+
+	seriesService := services.NewSeriesService(db, rabbitMQConfig, seriesHorizon)
+	seriesService.StartMaterializer()
+	roomService := services.NewRoomService(db, roomProvider)
+	roomService.StartRoomSync()
+	referenceService := services.NewReferenceService(db, courseServiceURL)
 
 	app := fiber.New()
 	app.Static("/docs", "./public/")
 
-	routes.ClassRoutes(app, rabbitMQConfig, db)
+	routes.ClassRoutes(app, rabbitMQConfig, db, writeMode, seriesService, roomService)
+	routes.AdminRoutes(app, rabbitMQConfig, db, referenceService, roomService)
+	routes.HealthRoutes(app, rabbitMQConfig, db)
 
 	log.Println("Starting server on :3000...")
 	if err := app.Listen(":3000"); err != nil {
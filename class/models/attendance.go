@@ -0,0 +1,115 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ways attendance is recorded.
+const (
+	AttendanceByInstructor = "instructor"
+	AttendanceSelfCheckIn  = "self"
+	AttendanceImported     = "import"
+)
+
+// Attendance records that a learner attended a class. A learner attends a class at most once; the
+// course of the class is kept for completion tracking.
+type Attendance struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClassID     uint      `json:"class_id" gorm:"not null;uniqueIndex:idx_attendances_class_learner"`
+	Class       *Class    `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	CourseID    uint      `json:"course_id" gorm:"not null;index"`
+	LearnerID   uint      `json:"learner_id" gorm:"not null;uniqueIndex:idx_attendances_class_learner"`
+	Method      string    `json:"method" gorm:"size:20;not null"`
+	CheckedInAt time.Time `json:"checked_in_at" gorm:"not null"`
+	// Minutes is how long the learner stayed, when the import reports it.
+	Minutes   uint      `json:"minutes"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CheckInCode lets the enrolled learners of a class check themselves in while it runs. Issuing a
+// new code revokes the previous one.
+type CheckInCode struct {
+	ClassID   uint      `json:"class_id" gorm:"primaryKey;autoIncrement:false"`
+	Class     *Class    `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Code      string    `json:"code" gorm:"size:16;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// checkInCodeAlphabet leaves out the characters easily mistaken for one another.
+const (
+	checkInCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	checkInCodeLength   = 6
+)
+
+// RecordAttendance inserts the attendance of a learner unless it is already recorded. It reports
+// whether it was inserted.
+func RecordAttendance(db *gorm.DB, attendance *Attendance) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(attendance)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func GetAttendance(db *gorm.DB, classID, learnerID uint) (*Attendance, error) {
+	var attendance Attendance
+	if err := db.Where("class_id = ? AND learner_id = ?", classID, learnerID).First(&attendance).Error; err != nil {
+		return nil, err
+	}
+	return &attendance, nil
+}
+
+// GetClassAttendances returns the attendances of a class, in check-in order.
+func GetClassAttendances(db *gorm.DB, classID uint) ([]Attendance, error) {
+	var attendances []Attendance
+	if err := db.Where("class_id = ?", classID).Order("checked_in_at, id").Find(&attendances).Error; err != nil {
+		return nil, err
+	}
+	return attendances, nil
+}
+
+// GetEnrolledLearners returns the learners holding a seat in a class.
+func GetEnrolledLearners(db *gorm.DB, classID uint) ([]uint, error) {
+	var learners []uint
+	err := db.Model(&Enrollment{}).
+		Where("class_id = ? AND status = ?", classID, EnrollmentEnrolled).
+		Order("learner_id").
+		Pluck("learner_id", &learners).Error
+	return learners, err
+}
+
+// IssueCheckInCode generates a new check-in code for a class, replacing the previous one.
+func IssueCheckInCode(db *gorm.DB, classID uint) (*CheckInCode, error) {
+	code := make([]byte, checkInCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(checkInCodeAlphabet))))
+		if err != nil {
+			return nil, err
+		}
+		code[i] = checkInCodeAlphabet[n.Int64()]
+	}
+
+	checkInCode := CheckInCode{ClassID: classID, Code: string(code), CreatedAt: time.Now()}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "class_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"code", "created_at"}),
+	}).Create(&checkInCode).Error
+	if err != nil {
+		return nil, err
+	}
+	return &checkInCode, nil
+}
+
+func GetCheckInCode(db *gorm.DB, classID uint) (*CheckInCode, error) {
+	var checkInCode CheckInCode
+	if err := db.First(&checkInCode, "class_id = ?", classID).Error; err != nil {
+		return nil, err
+	}
+	return &checkInCode, nil
+}
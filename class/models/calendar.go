@@ -0,0 +1,108 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CalendarToken authenticates the calendar feed of a learner. Issuing a new token revokes the previous one.
+type CalendarToken struct {
+	LearnerID uint      `json:"learner_id" gorm:"primaryKey;autoIncrement:false"`
+	Token     string    `json:"token" gorm:"size:64;not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// LearnerClass is a class a learner enrolled in, with the status of their enrollment.
+type LearnerClass struct {
+	Class
+	EnrollmentStatus string `json:"enrollment_status"`
+}
+
+// IssueCalendarToken generates a new calendar token for a learner, replacing the previous one.
+func IssueCalendarToken(db *gorm.DB, learnerID uint) (*CalendarToken, error) {
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := CalendarToken{LearnerID: learnerID, Token: secret, CreatedAt: time.Now()}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "learner_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "created_at"}),
+	}).Create(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// newSecretToken returns a random token, hex encoded, to authenticate links handed out by URL.
+func newSecretToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// GetCalendarTokenLearner returns the learner a calendar token was issued to.
+func GetCalendarTokenLearner(db *gorm.DB, token string) (uint, error) {
+	var calendarToken CalendarToken
+	if err := db.Where("token = ?", token).First(&calendarToken).Error; err != nil {
+		return 0, err
+	}
+	return calendarToken.LearnerID, nil
+}
+
+// GetCalendarClass returns a class, deleted or not.
+func GetCalendarClass(db *gorm.DB, id uint) (*Class, error) {
+	var class Class
+	if err := db.Unscoped().First(&class, id).Error; err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// GetCalendarClasses returns up to limit classes matching the filter, deleted ones included, by schedule.
+func GetCalendarClasses(db *gorm.DB, filter ClassFilter, limit int) ([]Class, error) {
+	query := db.Unscoped()
+	if filter.CompanyID != 0 {
+		query = query.Where("company_id = ?", filter.CompanyID)
+	}
+	if filter.CourseID != 0 {
+		query = query.Where("course_id = ?", filter.CourseID)
+	}
+	if filter.InstructorID != 0 {
+		query = query.Where("instructor_id = ?", filter.InstructorID)
+	}
+	if filter.ScheduledFrom != nil {
+		query = query.Where("scheduled_at >= ?", *filter.ScheduledFrom)
+	}
+
+	var classes []Class
+	if err := query.Order("scheduled_at, id").Limit(limit).Find(&classes).Error; err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// GetLearnerClasses returns up to limit classes a learner enrolled in from the given time on,
+// deleted ones and cancelled enrollments included, by schedule.
+func GetLearnerClasses(db *gorm.DB, learnerID uint, from time.Time, limit int) ([]LearnerClass, error) {
+	var classes []LearnerClass
+	err := db.Unscoped().Model(&Class{}).
+		Select("classes.*, enrollments.status AS enrollment_status").
+		Joins("JOIN enrollments ON enrollments.class_id = classes.id").
+		Where("enrollments.learner_id = ? AND classes.scheduled_at >= ?", learnerID, from).
+		Order("classes.scheduled_at, classes.id").
+		Limit(limit).
+		Find(&classes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the history of a class.
+const (
+	ClassChangeCancelled   = "cancelled"
+	ClassChangeRescheduled = "rescheduled"
+)
+
+// ClassChange records a cancellation or a rescheduling of a class, with its reason and the slot the
+// class held before. Rescheduling records the new slot too.
+type ClassChange struct {
+	ID                  uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClassID             uint       `json:"class_id" gorm:"not null;index"`
+	Class               *Class     `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Action              string     `json:"action" gorm:"size:20;not null"`
+	Reason              string     `json:"reason" gorm:"size:1024"`
+	PreviousScheduledAt time.Time  `json:"previous_scheduled_at" gorm:"not null"`
+	PreviousDuration    uint       `json:"previous_duration" gorm:"not null"`
+	ScheduledAt         *time.Time `json:"scheduled_at,omitempty"`
+	Duration            uint       `json:"duration,omitempty"`
+	CreatedAt           time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func RecordClassChange(db *gorm.DB, change *ClassChange) error {
+	return db.Create(change).Error
+}
+
+// GetClassChanges returns the history of a class, oldest first.
+func GetClassChanges(db *gorm.DB, classID uint) ([]ClassChange, error) {
+	var changes []ClassChange
+	if err := db.Where("class_id = ?", classID).Order("created_at, id").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrClassTypeUnavailable = errors.New("class type not available to the company")
+
+// AvailableTo reports whether a company may use the class type.
+func (t *ClassType) AvailableTo(companyID uint) bool {
+	return t.CompanyID == nil || *t.CompanyID == companyID
+}
+
+// GetClassTypes returns the default class types followed by the company's own, by name.
+func GetClassTypes(db *gorm.DB, companyID uint) ([]ClassType, error) {
+	var classTypes []ClassType
+	err := db.Where("company_id IS NULL OR company_id = ?", companyID).
+		Order("company_id NULLS FIRST, name").
+		Find(&classTypes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classTypes, nil
+}
+
+func GetClassTypeByID(db *gorm.DB, id uint) (*ClassType, error) {
+	var classType ClassType
+	if err := db.First(&classType, id).Error; err != nil {
+		return nil, err
+	}
+	return &classType, nil
+}
+
+func CreateClassType(db *gorm.DB, classType *ClassType) error {
+	return db.Create(classType).Error
+}
+
+func UpdateClassType(db *gorm.DB, id uint, classType *ClassType) error {
+	return db.Model(&ClassType{}).Where("id = ?", id).
+		Select("name", "description", "default_duration", "default_capacity", "default_waitlist_enabled", "online").
+		Updates(classType).Error
+}
+
+func DeleteClassType(db *gorm.DB, id uint) error {
+	return db.Delete(&ClassType{}, id).Error
+}
+
+// CountClassesOfType counts the classes and series of a type, deleted ones included since they still
+// reference it.
+func CountClassesOfType(db *gorm.DB, classTypeID uint) (int64, error) {
+	var classes, series int64
+	if err := db.Unscoped().Model(&Class{}).Where("class_type_id = ?", classTypeID).Count(&classes).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Unscoped().Model(&ClassSeries{}).Where("class_type_id = ?", classTypeID).Count(&series).Error; err != nil {
+		return 0, err
+	}
+	return classes + series, nil
+}
+
+// ReassignClassType moves every class and series of one type to another, deleted ones included.
+func ReassignClassType(db *gorm.DB, fromID, toID uint) error {
+	if err := db.Unscoped().Model(&Class{}).Where("class_type_id = ?", fromID).Update("class_type_id", toID).Error; err != nil {
+		return err
+	}
+	return db.Unscoped().Model(&ClassSeries{}).Where("class_type_id = ?", fromID).Update("class_type_id", toID).Error
+}
+
+// CheckClassTypeChange fails with ErrClassTypeUnavailable unless the type and company changes give
+// an existing class exist and go together. Zero fields of changes keep those of the class.
+func CheckClassTypeChange(db *gorm.DB, existing *Class, changes *Class) error {
+	classTypeID, companyID := existing.ClassTypeID, existing.CompanyID
+	if changes.ClassTypeID != 0 {
+		classTypeID = changes.ClassTypeID
+	}
+	if changes.CompanyID != 0 {
+		companyID = changes.CompanyID
+	}
+	return CheckClassType(db, classTypeID, companyID)
+}
+
+// CheckClassType fails with ErrClassTypeUnavailable unless the class type exists and is available
+// to the company.
+func CheckClassType(db *gorm.DB, classTypeID, companyID uint) error {
+	classType, err := GetClassTypeByID(db, classTypeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrClassTypeUnavailable
+	}
+	if err != nil {
+		return err
+	}
+	if !classType.AvailableTo(companyID) {
+		return ErrClassTypeUnavailable
+	}
+	return nil
+}
+
+func applyClassTypeDefaults(db *gorm.DB, class *Class) error {
+	classType, err := GetClassTypeByID(db, class.ClassTypeID)
+	if err != nil {
+		return err
+	}
+	if !classType.AvailableTo(class.CompanyID) {
+		return ErrClassTypeUnavailable
+	}
+
+	if class.Duration == 0 {
+		class.Duration = classType.DefaultDuration
+	}
+	if class.MaxParticipants == 0 {
+		class.MaxParticipants = classType.DefaultCapacity
+	}
+	if classType.DefaultWaitlistEnabled {
+		class.WaitlistEnabled = true
+	}
+	if classType.Online {
+		class.Online = true
+	}
+	return nil
+}
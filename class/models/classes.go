@@ -6,21 +6,33 @@ import (
 	"gorm.io/gorm"
 )
 
+// ClassType is a kind of session. Types without a company are the defaults every company inherits;
+// the others belong to a single company. Their defaults fill in the classes created with them.
 type ClassType struct {
-	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name        string    `json:"name" gorm:"size:100;not null;unique"`
-	Description string    `json:"description" gorm:"size:1024"`
-	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                     uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	CompanyID              *uint     `json:"company_id" gorm:"uniqueIndex:idx_class_types_company_name"`
+	Name                   string    `json:"name" gorm:"size:100;not null;uniqueIndex:idx_class_types_company_name"`
+	Description            string    `json:"description" gorm:"size:1024"`
+	DefaultDuration        uint      `json:"default_duration"`
+	DefaultCapacity        uint      `json:"default_capacity"`
+	DefaultWaitlistEnabled bool      `json:"default_waitlist_enabled" gorm:"default:false"`
+	Online                 bool      `json:"online" gorm:"default:false"`
+	CreatedAt              time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt              time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 var defaultClassTypes = []ClassType{
-	{Name: "Webinar", Description: "Live online presentations."},
-	{Name: "Demo", Description: "Product demonstrations."},
-	{Name: "Tutorial", Description: "Step-by-step instructional sessions."},
-	{Name: "Masterclass", Description: "In-depth sessions by experts."},
+	{Name: "Webinar", Description: "Live online presentations.", DefaultDuration: 60, DefaultCapacity: 500, Online: true},
+	{Name: "Demo", Description: "Product demonstrations.", DefaultDuration: 30, DefaultCapacity: 50, Online: true},
+	{Name: "Tutorial", Description: "Step-by-step instructional sessions.", DefaultDuration: 45, DefaultCapacity: 30, DefaultWaitlistEnabled: true},
+	{Name: "Masterclass", Description: "In-depth sessions by experts.", DefaultDuration: 120, DefaultCapacity: 20, DefaultWaitlistEnabled: true},
 }
 
+const (
+	ClassStatusScheduled = "scheduled"
+	ClassStatusCancelled = "cancelled"
+)
+
 type Class struct {
 	ID              uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	Title           string    `json:"title" gorm:"size:255;not null"`
@@ -29,33 +41,154 @@ type Class struct {
 	CourseID        uint      `json:"course_id" gorm:"not null"`
 	InstructorID    uint      `json:"instructor_id" gorm:"not null"`
 	ScheduledAt     time.Time `json:"scheduled_at" gorm:"not null"`
+	TimeZone        string    `json:"time_zone" gorm:"size:64;not null;default:UTC"`
+	LocalStart      string    `json:"local_start" gorm:"size:19"`
 	Duration        uint      `json:"duration" gorm:"not null"`
 	MaxParticipants uint      `json:"max_participants" gorm:"not null"`
 	CurrentEnrolled uint      `json:"current_enrolled" gorm:"default:0"`
 	WaitlistEnabled bool      `json:"waitlist_enabled" gorm:"default:false"`
 	ClassTypeID     uint      `json:"class_type_id" gorm:"not null"`
+	Room            string    `json:"room" gorm:"size:255"`
+	StreamKey       string    `json:"stream_key" gorm:"size:255"`
+	Online          bool      `json:"online" gorm:"default:false"`
 	ClassType       ClassType `json:"class_type" gorm:"foreignKey:ClassTypeID"`
 	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Status is scheduled until the class is cancelled. Cancelled classes are kept, with their
+	// enrollments, and free their slot.
+	Status      string     `json:"status" gorm:"size:20;not null;default:scheduled;index"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+
+	// CourseDeletedAt flags the classes whose course was deleted in the course service. Those yet to
+	// start are cancelled with it; the others are kept as they were.
+	CourseDeletedAt *time.Time `json:"course_deleted_at"`
+
+	// Sequence counts the revisions of the class, as published in calendars. Deleted classes are
+	// kept so that calendars can publish their cancellation.
+	Sequence  uint           `json:"sequence" gorm:"default:0"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// SeriesID and OccurrenceAt identify the occurrence of a series the class was materialised
+	// from; OccurrenceAt keeps the original start when the class is rescheduled. Detached classes
+	// were edited on their own and no longer follow edits of the series.
+	SeriesID     *uint        `json:"series_id" gorm:"uniqueIndex:idx_classes_series_occurrence"`
+	Series       *ClassSeries `json:"-"`
+	OccurrenceAt *time.Time   `json:"occurrence_at" gorm:"uniqueIndex:idx_classes_series_occurrence"`
+	Detached     bool         `json:"detached" gorm:"default:false"`
 }
 
+// CreateClass inserts a class, filling its zero duration and capacity from its type's defaults.
+// The waitlist is enabled, and the class held online, when either the class or its type says so.
+// Its start may be given as a local time in its time zone or as an instant. It fails with a
+// *ConflictError when the class overlaps another one; run it inside a transaction so that
+// concurrent bookings are serialized.
 func CreateClass(db *gorm.DB, class *Class) error {
+	if err := class.ResolveSchedule(); err != nil {
+		return err
+	}
+	if err := applyClassTypeDefaults(db, class); err != nil {
+		return err
+	}
+	if err := checkConflicts(db, class); err != nil {
+		return err
+	}
+	class.Status = ClassStatusScheduled
+	class.CancelledAt = nil
+	// Seats are only ever counted through SetEnrolledCount.
+	class.CurrentEnrolled = 0
 	return db.Create(class).Error
 }
 
+func GetClassByID(db *gorm.DB, id uint) (*Class, error) {
+	var class Class
+	if err := db.Preload("ClassType").First(&class, id).Error; err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// UpdateClass applies the non-zero fields of class to an existing class, except its status, which
+// only CancelClass changes, and its enrolled count, which only SetEnrolledCount changes. A new time
+// zone alone keeps the local time of the class, moving its instant. It fails with
+// ErrClassTypeUnavailable when the class would end up with a type its company may not use, and
+// with a *ConflictError when the updated class overlaps another one, after writing it; run it
+// inside a transaction so that the update is rolled back.
 func UpdateClass(db *gorm.DB, id uint, class *Class) error {
-	return db.Model(&Class{}).Where("id = ?", id).Updates(class).Error
+	if class.ClassTypeID != 0 || class.CompanyID != 0 {
+		existing, err := GetClassByID(db, id)
+		if err != nil {
+			return err
+		}
+		if err := CheckClassTypeChange(db, existing, class); err != nil {
+			return err
+		}
+	}
+	if class.TimeZone != "" || class.LocalStart != "" || !class.ScheduledAt.IsZero() {
+		existing, err := GetClassByID(db, id)
+		if err != nil {
+			return err
+		}
+		if err := class.ResolveScheduleChange(existing); err != nil {
+			return err
+		}
+	}
+	if err := db.Model(&Class{}).Where("id = ?", id).Omit("sequence", "status", "cancelled_at", "course_deleted_at", "current_enrolled").Updates(class).Error; err != nil {
+		return err
+	}
+	if err := bumpSequence(db, id); err != nil {
+		return err
+	}
+	updated, err := GetClassByID(db, id)
+	if err != nil {
+		return err
+	}
+	return checkConflicts(db, updated)
 }
 
 func DeleteClass(db *gorm.DB, id uint) error {
+	if err := bumpSequence(db, id); err != nil {
+		return err
+	}
 	return db.Delete(&Class{}, id).Error
 }
 
+// CancelClass marks a class cancelled, releasing all its seats. Cancelled occurrences of a series
+// are detached so that edits of the series leave them cancelled.
+func CancelClass(db *gorm.DB, id uint, at time.Time) error {
+	err := db.Model(&Class{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":           ClassStatusCancelled,
+		"cancelled_at":     at,
+		"current_enrolled": 0,
+		"detached":         gorm.Expr("series_id IS NOT NULL"),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return bumpSequence(db, id)
+}
+
+// Cancelled reports whether the class was cancelled.
+func (c *Class) Cancelled() bool {
+	return c.Status == ClassStatusCancelled
+}
+
+func bumpSequence(db *gorm.DB, id uint) error {
+	return db.Model(&Class{}).Where("id = ?", id).UpdateColumn("sequence", gorm.Expr("sequence + 1")).Error
+}
+
 func MigrateDefaultClassTypes(db *gorm.DB) error {
+	// Names used to be unique across companies.
+	if db.Migrator().HasConstraint(&ClassType{}, "uni_class_types_name") {
+		if err := db.Migrator().DropConstraint(&ClassType{}, "uni_class_types_name"); err != nil {
+			return err
+		}
+	}
+
 	for _, classType := range defaultClassTypes {
 		var count int64
 		// Check if the class type already exists
-		if err := db.Model(&ClassType{}).Where("name = ?", classType.Name).Count(&count).Error; err != nil {
+		if err := db.Model(&ClassType{}).Where("name = ? AND company_id IS NULL", classType.Name).Count(&count).Error; err != nil {
 			return err
 		}
 		// Insert if it does not exist
@@ -63,14 +196,61 @@ func MigrateDefaultClassTypes(db *gorm.DB) error {
 			if err := db.Create(&classType).Error; err != nil {
 				return err
 			}
+			continue
+		}
+		// Default types are read-only, so their delivery follows this release.
+		err := db.Model(&ClassType{}).Where("name = ? AND company_id IS NULL", classType.Name).
+			Update("online", classType.Online).Error
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func GetAllClasses(db *gorm.DB) ([]Class, error) {
+// ClassFilter narrows the classes returned by GetAllClasses. Zero values are ignored.
+type ClassFilter struct {
+	CompanyID     uint
+	CourseID      uint
+	InstructorID  uint
+	ClassTypeID   uint
+	ScheduledFrom *time.Time
+	ScheduledTo   *time.Time
+}
+
+// ClassCursor is the position of the last class of a page, classes being ordered by schedule then ID.
+type ClassCursor struct {
+	ScheduledAt time.Time
+	ID          uint
+}
+
+func GetAllClasses(db *gorm.DB, filter ClassFilter, after *ClassCursor, limit int) ([]Class, error) {
+	query := db.Preload("ClassType")
+
+	if filter.CompanyID != 0 {
+		query = query.Where("company_id = ?", filter.CompanyID)
+	}
+	if filter.CourseID != 0 {
+		query = query.Where("course_id = ?", filter.CourseID)
+	}
+	if filter.InstructorID != 0 {
+		query = query.Where("instructor_id = ?", filter.InstructorID)
+	}
+	if filter.ClassTypeID != 0 {
+		query = query.Where("class_type_id = ?", filter.ClassTypeID)
+	}
+	if filter.ScheduledFrom != nil {
+		query = query.Where("scheduled_at >= ?", *filter.ScheduledFrom)
+	}
+	if filter.ScheduledTo != nil {
+		query = query.Where("scheduled_at < ?", *filter.ScheduledTo)
+	}
+	if after != nil {
+		query = query.Where("(scheduled_at, id) > (?, ?)", after.ScheduledAt, after.ID)
+	}
+
 	var classes []Class
-	if err := db.Preload("ClassType").Find(&classes).Error; err != nil {
+	if err := query.Order("scheduled_at, id").Limit(limit).Find(&classes).Error; err != nil {
 		return nil, err
 	}
 	return classes, nil
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConflictError lists the classes a class would overlap: same instructor, or same company room or
+// stream key.
+type ConflictError struct {
+	Conflicts []Class
+}
+
+func (e *ConflictError) Error() string {
+	ids := make([]string, 0, len(e.Conflicts))
+	for _, class := range e.Conflicts {
+		ids = append(ids, fmt.Sprint(class.ID))
+	}
+	return "class overlaps classes " + strings.Join(ids, ", ")
+}
+
+// End returns when the class finishes, its duration being in minutes.
+func (c *Class) End() time.Time {
+	return c.ScheduledAt.Add(time.Duration(c.Duration) * time.Minute)
+}
+
+// overlapping restricts a query to the classes running at some point in [from, to). Cancelled
+// classes do not run.
+func overlapping(db *gorm.DB, from, to time.Time) *gorm.DB {
+	return db.Where("classes.scheduled_at < ? AND classes.scheduled_at + classes.duration * interval '1 minute' > ?", to, from).
+		Where("classes.status <> ?", ClassStatusCancelled)
+}
+
+// checkConflicts fails with a *ConflictError when another class overlaps class. It first takes
+// transaction-scoped locks on the instructor, room and stream key, so that concurrent bookings of
+// the same resources wait for each other.
+func checkConflicts(db *gorm.DB, class *Class) error {
+	resources := db.Where("instructor_id = ?", class.InstructorID)
+	keys := []string{fmt.Sprintf("instructor:%d", class.InstructorID)}
+	if class.Room != "" {
+		resources = resources.Or("company_id = ? AND room = ?", class.CompanyID, class.Room)
+		keys = append(keys, fmt.Sprintf("room:%d:%s", class.CompanyID, class.Room))
+	}
+	if class.StreamKey != "" {
+		resources = resources.Or("stream_key = ?", class.StreamKey)
+		keys = append(keys, "stream:"+class.StreamKey)
+	}
+
+	for _, key := range keys {
+		if err := db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+			return err
+		}
+	}
+
+	var conflicts []Class
+	query := overlapping(db.Model(&Class{}), class.ScheduledAt, class.End()).Where(resources)
+	if class.ID != 0 {
+		query = query.Where("id <> ?", class.ID)
+	}
+	if err := query.Order("scheduled_at, id").Find(&conflicts).Error; err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// GetInstructorClasses returns the classes of an instructor running at some point in [from, to), in order.
+func GetInstructorClasses(db *gorm.DB, instructorID uint, from, to time.Time) ([]Class, error) {
+	var classes []Class
+	err := overlapping(db.Where("instructor_id = ?", instructorID), from, to).
+		Order("scheduled_at, id").
+		Find(&classes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownCourse     = errors.New("unknown course")
+	ErrUnknownInstructor = errors.New("unknown instructor")
+)
+
+// CourseReference is a course of the course service, as known from its events. Classes and series
+// may only point at courses known here. Deleted courses are kept, deleted, so that late events
+// cannot bring them back.
+type CourseReference struct {
+	ID             uint           `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Title          string         `json:"title" gorm:"size:255"`
+	ParentCourseID *uint          `json:"parent_course_id" gorm:"index"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// InstructorReference is an instructor of the course service, as known from the courses they teach.
+type InstructorReference struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"size:255"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveCourseReference inserts or updates a known course, unless it was deleted or already holds a
+// newer revision.
+func SaveCourseReference(db *gorm.DB, course *CourseReference) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "parent_course_id", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+			SQL: "course_references.deleted_at IS NULL AND course_references.updated_at <= excluded.updated_at",
+		}}},
+	}).Create(course).Error
+}
+
+func SaveInstructorReference(db *gorm.DB, instructor *InstructorReference) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at"}),
+	}).Create(instructor).Error
+}
+
+// DeleteCourseReference marks a course and its sub-courses deleted at at, as the course service
+// deletes them together. It returns their IDs.
+func DeleteCourseReference(db *gorm.DB, id uint, at time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`WITH RECURSIVE tree AS (
+			SELECT id FROM course_references WHERE id = ? AND deleted_at IS NULL
+			UNION
+			SELECT c.id FROM course_references c JOIN tree ON c.parent_course_id = tree.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM tree`, id).Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// A course deleted before its creation arrived is remembered too.
+	tombstone := CourseReference{ID: id, UpdatedAt: at, DeletedAt: gorm.DeletedAt{Time: at, Valid: true}}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"deleted_at"}),
+	}).Create(&tombstone).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []uint{id}, nil
+	}
+	if err := db.Model(&CourseReference{}).Where("id IN ?", ids).UpdateColumn("deleted_at", at).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ClearReferences forgets every known course and instructor, deleted courses included.
+func ClearReferences(db *gorm.DB) error {
+	global := db.Session(&gorm.Session{AllowGlobalUpdate: true})
+	if err := global.Unscoped().Delete(&CourseReference{}).Error; err != nil {
+		return err
+	}
+	return global.Delete(&InstructorReference{}).Error
+}
+
+// CheckCourse fails with ErrUnknownCourse unless the course is known and not deleted.
+func CheckCourse(db *gorm.DB, id uint) error {
+	var count int64
+	if err := db.Model(&CourseReference{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w %d", ErrUnknownCourse, id)
+	}
+	return nil
+}
+
+// CheckInstructor fails with ErrUnknownInstructor unless the instructor is known.
+func CheckInstructor(db *gorm.DB, id uint) error {
+	var count int64
+	if err := db.Model(&InstructorReference{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w %d", ErrUnknownInstructor, id)
+	}
+	return nil
+}
+
+// CheckReferences fails with ErrUnknownCourse or ErrUnknownInstructor unless both the course and the
+// instructor are known.
+func CheckReferences(db *gorm.DB, courseID, instructorID uint) error {
+	if err := CheckCourse(db, courseID); err != nil {
+		return err
+	}
+	return CheckInstructor(db, instructorID)
+}
+
+// CheckChangedReferences checks the course and instructor an update points a class or series at.
+// Zero IDs are left unchecked, as updates leave them unchanged.
+func CheckChangedReferences(db *gorm.DB, courseID, instructorID uint) error {
+	if courseID != 0 {
+		if err := CheckCourse(db, courseID); err != nil {
+			return err
+		}
+	}
+	if instructorID != 0 {
+		return CheckInstructor(db, instructorID)
+	}
+	return nil
+}
+
+// GetCourseClasses returns the classes of the given courses.
+func GetCourseClasses(db *gorm.DB, courseIDs []uint) ([]Class, error) {
+	var classes []Class
+	if err := db.Where("course_id IN ?", courseIDs).Order("scheduled_at, id").Find(&classes).Error; err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// GetCourseSeries returns the series of the given courses.
+func GetCourseSeries(db *gorm.DB, courseIDs []uint) ([]ClassSeries, error) {
+	var series []ClassSeries
+	if err := db.Where("course_id IN ?", courseIDs).Order("id").Find(&series).Error; err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// FlagCourseDeleted records on the classes of the given courses that their course was deleted.
+func FlagCourseDeleted(db *gorm.DB, courseIDs []uint, at time.Time) error {
+	return db.Model(&Class{}).Where("course_id IN ? AND course_deleted_at IS NULL", courseIDs).
+		UpdateColumn("course_deleted_at", at).Error
+}
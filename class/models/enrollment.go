@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	EnrollmentEnrolled   = "enrolled"
+	EnrollmentWaitlisted = "waitlisted"
+	EnrollmentCancelled  = "cancelled"
+)
+
+// Enrollment is a learner's seat in a class, or place on its waitlist. A learner has at most one
+// enrollment per class, reused when they enroll again after cancelling.
+type Enrollment struct {
+	ID           uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClassID      uint       `json:"class_id" gorm:"not null;uniqueIndex:idx_enrollments_class_learner"`
+	Class        *Class     `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	LearnerID    uint       `json:"learner_id" gorm:"not null;uniqueIndex:idx_enrollments_class_learner"`
+	Status       string     `json:"status" gorm:"size:20;not null;index"`
+	EnrolledAt   *time.Time `json:"enrolled_at"`
+	WaitlistedAt *time.Time `json:"waitlisted_at"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Active reports whether the enrollment holds a seat or a place on the waitlist.
+func (e *Enrollment) Active() bool {
+	return e.Status == EnrollmentEnrolled || e.Status == EnrollmentWaitlisted
+}
+
+// LockClass reads a class and locks its row until the end of the transaction, serializing the
+// enrollments of the class.
+func LockClass(tx *gorm.DB, id uint) (*Class, error) {
+	var class Class
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&class, id).Error; err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// SetEnrolledCount stores the number of learners holding a seat in a class.
+func SetEnrolledCount(db *gorm.DB, classID uint, count uint) error {
+	return db.Model(&Class{}).Where("id = ?", classID).Update("current_enrolled", count).Error
+}
+
+func GetEnrollment(db *gorm.DB, classID, learnerID uint) (*Enrollment, error) {
+	var enrollment Enrollment
+	if err := db.Where("class_id = ? AND learner_id = ?", classID, learnerID).First(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func SaveEnrollment(db *gorm.DB, enrollment *Enrollment) error {
+	return db.Save(enrollment).Error
+}
+
+// NextWaitlisted returns the learner waiting longest for a seat in a class.
+func NextWaitlisted(db *gorm.DB, classID uint) (*Enrollment, error) {
+	var enrollment Enrollment
+	err := db.Where("class_id = ? AND status = ?", classID, EnrollmentWaitlisted).
+		Order("waitlisted_at, id").
+		First(&enrollment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// GetActiveEnrollments returns the enrollments of a class holding a seat or a place on its
+// waitlist, in the order they were made.
+func GetActiveEnrollments(db *gorm.DB, classID uint) ([]Enrollment, error) {
+	var enrollments []Enrollment
+	err := db.Where("class_id = ? AND status IN ?", classID, []string{EnrollmentEnrolled, EnrollmentWaitlisted}).
+		Order("created_at, id").
+		Find(&enrollments).Error
+	if err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+
+// GetBusyLearners returns which of learners hold an enrollment in another class running at some
+// point in [from, to).
+func GetBusyLearners(db *gorm.DB, classID uint, learners []uint, from, to time.Time) ([]uint, error) {
+	if len(learners) == 0 {
+		return nil, nil
+	}
+	var busy []uint
+	err := overlapping(db.Model(&Enrollment{}).Joins("JOIN classes ON classes.id = enrollments.class_id AND classes.deleted_at IS NULL"), from, to).
+		Where("enrollments.learner_id IN ? AND enrollments.status IN ? AND classes.id <> ?",
+			learners, []string{EnrollmentEnrolled, EnrollmentWaitlisted}, classID).
+		Distinct().
+		Pluck("enrollments.learner_id", &busy).Error
+	return busy, err
+}
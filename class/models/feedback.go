@@ -0,0 +1,128 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Moderation statuses of feedback. Flagged feedback awaits a moderator and still counts; hidden
+// feedback is left out of listings and aggregates.
+const (
+	FeedbackVisible = "visible"
+	FeedbackFlagged = "flagged"
+	FeedbackHidden  = "hidden"
+)
+
+// Feedback is the rating a learner gave a class they attended, from 1 to 5, and optionally its
+// instructor. The course, type and instructor of the class are kept for aggregation.
+type Feedback struct {
+	ID                uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClassID           uint      `json:"class_id" gorm:"not null;uniqueIndex:idx_feedbacks_class_learner"`
+	Class             *Class    `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	LearnerID         uint      `json:"learner_id" gorm:"not null;uniqueIndex:idx_feedbacks_class_learner"`
+	CourseID          uint      `json:"course_id" gorm:"not null;index"`
+	ClassTypeID       uint      `json:"class_type_id" gorm:"not null;index"`
+	InstructorID      uint      `json:"instructor_id" gorm:"not null;index"`
+	Rating            uint      `json:"rating" gorm:"not null"`
+	Comment           string    `json:"comment" gorm:"size:4096"`
+	InstructorRating  *uint     `json:"instructor_rating"`
+	InstructorComment string    `json:"instructor_comment" gorm:"size:4096"`
+	ModerationStatus  string    `json:"moderation_status" gorm:"size:20;not null;default:visible;index"`
+	FlagReason        string    `json:"flag_reason,omitempty" gorm:"size:1024"`
+	CreatedAt         time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// FeedbackFilter narrows the feedback aggregated by CountRatings. Zero values are ignored.
+type FeedbackFilter struct {
+	ClassID      uint
+	CourseID     uint
+	ClassTypeID  uint
+	InstructorID uint
+}
+
+// RatingCount is how many times a rating was given.
+type RatingCount struct {
+	Rating uint
+	Count  int64
+}
+
+func CreateFeedback(db *gorm.DB, feedback *Feedback) error {
+	return db.Create(feedback).Error
+}
+
+func GetFeedbackByID(db *gorm.DB, id uint) (*Feedback, error) {
+	var feedback Feedback
+	if err := db.First(&feedback, id).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+func FeedbackExists(db *gorm.DB, classID, learnerID uint) (bool, error) {
+	var count int64
+	err := db.Model(&Feedback{}).Where("class_id = ? AND learner_id = ?", classID, learnerID).Count(&count).Error
+	return count > 0, err
+}
+
+// GetClassFeedback returns the feedback of a class not hidden by moderation, newest first.
+func GetClassFeedback(db *gorm.DB, classID uint) ([]Feedback, error) {
+	var feedback []Feedback
+	err := db.Where("class_id = ? AND moderation_status <> ?", classID, FeedbackHidden).
+		Order("created_at DESC, id DESC").
+		Find(&feedback).Error
+	if err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// GetFlaggedFeedback returns up to limit feedback awaiting moderation, oldest first.
+func GetFlaggedFeedback(db *gorm.DB, limit int) ([]Feedback, error) {
+	var feedback []Feedback
+	err := db.Where("moderation_status = ?", FeedbackFlagged).
+		Order("updated_at, id").
+		Limit(limit).
+		Find(&feedback).Error
+	if err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// ModerateFeedback sets the moderation status of feedback, and the reason it was flagged for.
+func ModerateFeedback(db *gorm.DB, id uint, status, reason string) error {
+	return db.Model(&Feedback{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"moderation_status": status, "flag_reason": reason}).Error
+}
+
+// CountRatings counts the ratings of the feedback matching filter that moderation did not hide.
+// With instructor set, it counts the ratings given to instructors instead of classes.
+func CountRatings(db *gorm.DB, filter FeedbackFilter, instructor bool) ([]RatingCount, error) {
+	column := "rating"
+	if instructor {
+		column = "instructor_rating"
+	}
+
+	query := db.Model(&Feedback{}).Where("moderation_status <> ? AND "+column+" IS NOT NULL", FeedbackHidden)
+	if filter.ClassID != 0 {
+		query = query.Where("class_id = ?", filter.ClassID)
+	}
+	if filter.CourseID != 0 {
+		query = query.Where("course_id = ?", filter.CourseID)
+	}
+	if filter.ClassTypeID != 0 {
+		query = query.Where("class_type_id = ?", filter.ClassTypeID)
+	}
+	if filter.InstructorID != 0 {
+		query = query.Where("instructor_id = ?", filter.InstructorID)
+	}
+
+	var counts []RatingCount
+	err := query.Select(column + " AS rating, count(*) AS count").Group(column).Order(column).Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
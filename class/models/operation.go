@@ -0,0 +1,88 @@
+package models
+
+import (
+	"leecho/events"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OperationPending   = "pending"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// Operation tracks a command accepted by the API and carried out asynchronously by a consumer.
+type Operation struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:36"`
+	Type      string    `json:"type" gorm:"size:100;not null"`
+	EventID   string    `json:"event_id" gorm:"size:36;not null;uniqueIndex"`
+	Status    string    `json:"status" gorm:"size:20;not null;default:pending"`
+	EntityID  *uint     `json:"entity_id"`
+	Error     string    `json:"error,omitempty" gorm:"size:1024"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Done reports whether the operation reached a final status.
+func (o *Operation) Done() bool {
+	return o.Status != OperationPending
+}
+
+// EnqueueOperation records a pending operation and the event carrying its command in one transaction.
+func EnqueueOperation[T any](db *gorm.DB, event events.Envelope[T]) (*Operation, error) {
+	operation := Operation{
+		ID:      events.NewID(),
+		Type:    string(event.Type),
+		EventID: event.ID,
+		Status:  OperationPending,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&operation).Error; err != nil {
+			return err
+		}
+		return EnqueueEvent(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func GetOperationByID(db *gorm.DB, id string) (*Operation, error) {
+	var operation Operation
+	if err := db.First(&operation, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// CompleteOperation marks the operation carried by eventID as succeeded, recording the entity it produced.
+// Events without an operation are ignored.
+func CompleteOperation(db *gorm.DB, eventID string, entityID uint) error {
+	updates := map[string]interface{}{
+		"status": OperationSucceeded,
+		"error":  "",
+	}
+	if entityID != 0 {
+		updates["entity_id"] = entityID
+	}
+	return db.Model(&Operation{}).Where("event_id = ?", eventID).Updates(updates).Error
+}
+
+// RecordOperationError keeps the last error of a pending operation whose event is being retried.
+func RecordOperationError(db *gorm.DB, eventID, message string) error {
+	return db.Model(&Operation{}).
+		Where("event_id = ? AND status = ?", eventID, OperationPending).
+		Update("error", truncate(message, 1024)).Error
+}
+
+// FailOperation marks the operation carried by eventID as failed once its event is dead-lettered.
+func FailOperation(db *gorm.DB, eventID, message string) error {
+	return db.Model(&Operation{}).Where("event_id = ?", eventID).Updates(map[string]interface{}{
+		"status": OperationFailed,
+		"error":  truncate(message, 1024),
+	}).Error
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClassSeries is a class repeating by an RFC 5545 recurrence rule, expanded in its own time zone.
+// Its template fields are copied to the classes materialised for each occurrence.
+type ClassSeries struct {
+	ID                uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Title             string      `json:"title" gorm:"size:255;not null"`
+	Description       string      `json:"description" gorm:"size:1024"`
+	CompanyID         uint        `json:"company_id" gorm:"not null;index"`
+	CourseID          uint        `json:"course_id" gorm:"not null"`
+	InstructorID      uint        `json:"instructor_id" gorm:"not null"`
+	ClassTypeID       uint        `json:"class_type_id" gorm:"not null"`
+	Duration          uint        `json:"duration"`
+	MaxParticipants   uint        `json:"max_participants"`
+	WaitlistEnabled   bool        `json:"waitlist_enabled" gorm:"default:false"`
+	StartsAt          time.Time   `json:"starts_at" gorm:"not null"`
+	TimeZone          string      `json:"time_zone" gorm:"size:64;not null;default:UTC"`
+	LocalStart        string      `json:"local_start" gorm:"size:19"`
+	RecurrenceRule    string      `json:"rrule" gorm:"size:255;not null"`
+	ExceptionDates    []time.Time `json:"exception_dates" gorm:"type:jsonb;serializer:json"`
+	MaterializedUntil *time.Time  `json:"materialized_until"`
+	CreatedAt         time.Time   `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Deleted series are kept, like their classes, so that the history of past occurrences stays.
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// Occurrence returns the class of the series starting at at, not yet persisted.
+func (s *ClassSeries) Occurrence(at time.Time) Class {
+	seriesID := s.ID
+	at = at.UTC()
+	return Class{
+		Title:           s.Title,
+		Description:     s.Description,
+		CompanyID:       s.CompanyID,
+		CourseID:        s.CourseID,
+		InstructorID:    s.InstructorID,
+		ClassTypeID:     s.ClassTypeID,
+		ScheduledAt:     at,
+		TimeZone:        s.TimeZone,
+		LocalStart:      at.In(loadLocation(s.TimeZone)).Format(LocalTimeLayout),
+		Duration:        s.Duration,
+		MaxParticipants: s.MaxParticipants,
+		WaitlistEnabled: s.WaitlistEnabled,
+		SeriesID:        &seriesID,
+		OccurrenceAt:    &at,
+	}
+}
+
+// CreateSeries inserts a series, whose first start may be given as a local time in its time zone
+// or as an instant.
+func CreateSeries(db *gorm.DB, series *ClassSeries) error {
+	if err := series.resolveSchedule(); err != nil {
+		return err
+	}
+	return db.Create(series).Error
+}
+
+func GetSeriesByID(db *gorm.DB, id uint) (*ClassSeries, error) {
+	var series ClassSeries
+	if err := db.First(&series, id).Error; err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// LockSeries reads a series and locks its row until the end of the transaction.
+func LockSeries(tx *gorm.DB, id uint) (*ClassSeries, error) {
+	var series ClassSeries
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, id).Error; err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func SaveSeries(db *gorm.DB, series *ClassSeries) error {
+	return db.Save(series).Error
+}
+
+// DeleteSeries soft-deletes a series. Its classes are deleted on their own, through DeleteClass.
+func DeleteSeries(db *gorm.DB, id uint) error {
+	return db.Delete(&ClassSeries{}, id).Error
+}
+
+// GetAllSeries returns every series, for the materializer.
+func GetAllSeries(db *gorm.DB) ([]ClassSeries, error) {
+	var series []ClassSeries
+	if err := db.Order("id").Find(&series).Error; err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// GetSeriesClasses returns the classes of a series whose occurrence starts at or after from, in order.
+func GetSeriesClasses(db *gorm.DB, seriesID uint, from time.Time) ([]Class, error) {
+	var classes []Class
+	err := db.Preload("ClassType").
+		Where("series_id = ? AND occurrence_at >= ?", seriesID, from).
+		Order("occurrence_at").
+		Find(&classes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// MoveSeriesClasses moves the classes of a series whose occurrence starts at or after from to another series.
+func MoveSeriesClasses(db *gorm.DB, fromSeriesID, toSeriesID uint, from time.Time) error {
+	return db.Model(&Class{}).
+		Where("series_id = ? AND occurrence_at >= ?", fromSeriesID, from).
+		Update("series_id", toSeriesID).Error
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LocalTimeLayout is the layout of local wall times, which carry no offset.
+const LocalTimeLayout = "2006-01-02T15:04:05"
+
+var (
+	ErrInvalidTimeZone  = errors.New("invalid time zone")
+	ErrInvalidLocalTime = errors.New("invalid local time, expected YYYY-MM-DDTHH:MM:SS")
+)
+
+// resolveLocalTime completes a start given either as a local wall time in an IANA zone or as an
+// instant: the local time wins when both are set. An empty zone means UTC.
+func resolveLocalTime(zone, local *string, instant *time.Time) error {
+	if *zone == "" {
+		*zone = "UTC"
+	}
+	location, err := time.LoadLocation(*zone)
+	if err != nil {
+		return ErrInvalidTimeZone
+	}
+
+	if *local != "" {
+		wall, err := time.ParseInLocation(LocalTimeLayout, *local, location)
+		if err != nil {
+			return ErrInvalidLocalTime
+		}
+		*instant = wall.UTC()
+		return nil
+	}
+	if !instant.IsZero() {
+		*local = instant.In(location).Format(LocalTimeLayout)
+		*instant = instant.UTC()
+	}
+	return nil
+}
+
+// loadLocation returns the location of a zone validated on write, defaulting to UTC.
+func loadLocation(zone string) *time.Location {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Location returns the time zone of the class.
+func (c *Class) Location() *time.Location {
+	return loadLocation(c.TimeZone)
+}
+
+// ResolveSchedule completes the start of a class from its local time or its instant. It fails with
+// ErrInvalidTimeZone or ErrInvalidLocalTime.
+func (c *Class) ResolveSchedule() error {
+	return resolveLocalTime(&c.TimeZone, &c.LocalStart, &c.ScheduledAt)
+}
+
+// ResolveScheduleChange completes the start changes of an existing class like ResolveSchedule, the
+// zone or the start left out keeping those of the class. Changes leaving both out are left as they
+// are.
+func (c *Class) ResolveScheduleChange(existing *Class) error {
+	if c.TimeZone == "" && c.LocalStart == "" && c.ScheduledAt.IsZero() {
+		return nil
+	}
+	if c.TimeZone == "" {
+		c.TimeZone = existing.TimeZone
+	}
+	if c.LocalStart == "" && c.ScheduledAt.IsZero() {
+		c.LocalStart = existing.LocalStart
+	}
+	return c.ResolveSchedule()
+}
+
+// resolveSchedule completes the start of a series from its local time or its instant.
+func (s *ClassSeries) resolveSchedule() error {
+	return resolveLocalTime(&s.TimeZone, &s.LocalStart, &s.StartsAt)
+}
+
+// Start returns the first start of the series in its own zone, where its occurrences are expanded
+// so that they keep their wall time across DST changes.
+func (s *ClassSeries) Start() time.Time {
+	return s.StartsAt.In(loadLocation(s.TimeZone))
+}
+
+// MigrateLocalTimes fills the local times of classes and series created before time zones were
+// stored, which were all scheduled in UTC.
+func MigrateLocalTimes(db *gorm.DB) error {
+	const backfill = `UPDATE %s SET local_start = to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS')
+		WHERE local_start IS NULL OR local_start = ''`
+	if err := db.Exec(fmt.Sprintf(backfill, "classes", "scheduled_at")).Error; err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf(backfill, "class_series", "starts_at")).Error
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VirtualRoom is the room an online class is delivered in, as provisioned by a virtual room
+// provider. ClassSequence is the revision of the class the room was last synced with; rooms of
+// cancelled or deleted classes are released but kept for their recording. HostURL is only handed
+// out against a host token.
+type VirtualRoom struct {
+	ClassID       uint       `json:"class_id" gorm:"primaryKey;autoIncrement:false"`
+	Class         *Class     `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Provider      string     `json:"provider" gorm:"size:50;not null"`
+	RoomID        string     `json:"room_id" gorm:"size:255;not null"`
+	HostURL       string     `json:"-" gorm:"size:1024"`
+	JoinURL       string     `json:"join_url" gorm:"size:1024"`
+	DialIn        string     `json:"dial_in" gorm:"size:255"`
+	RecordingURL  string     `json:"recording_url" gorm:"size:1024"`
+	ClassSequence uint       `json:"-"`
+	ReleasedAt    *time.Time `json:"released_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RoomHostToken authenticates the host link of the room of a class, for the instructor it was
+// issued to. Issuing a new token revokes the previous one.
+type RoomHostToken struct {
+	ClassID      uint      `json:"class_id" gorm:"primaryKey;autoIncrement:false"`
+	InstructorID uint      `json:"instructor_id" gorm:"not null"`
+	Token        string    `json:"token" gorm:"size:64;not null;uniqueIndex"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// IssueRoomHostToken generates a new host token for the instructor of a class, replacing the
+// previous one.
+func IssueRoomHostToken(db *gorm.DB, classID, instructorID uint) (*RoomHostToken, error) {
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := RoomHostToken{ClassID: classID, InstructorID: instructorID, Token: secret, CreatedAt: time.Now()}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "class_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"instructor_id", "token", "created_at"}),
+	}).Create(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetRoomHostToken returns a host token by its value.
+func GetRoomHostToken(db *gorm.DB, token string) (*RoomHostToken, error) {
+	var hostToken RoomHostToken
+	if err := db.Where("token = ?", token).First(&hostToken).Error; err != nil {
+		return nil, err
+	}
+	return &hostToken, nil
+}
+
+func GetVirtualRoom(db *gorm.DB, classID uint) (*VirtualRoom, error) {
+	var room VirtualRoom
+	if err := db.First(&room, "class_id = ?", classID).Error; err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func SaveVirtualRoom(db *gorm.DB, room *VirtualRoom) error {
+	return db.Save(room).Error
+}
+
+// GetClassesNeedingRooms returns up to limit online classes, scheduled and not over yet, whose room
+// is missing, released or older than the class.
+func GetClassesNeedingRooms(db *gorm.DB, now time.Time, limit int) ([]Class, error) {
+	var classes []Class
+	err := db.Joins("LEFT JOIN virtual_rooms ON virtual_rooms.class_id = classes.id").
+		Where("classes.online AND classes.status = ?", ClassStatusScheduled).
+		Where("classes.scheduled_at + classes.duration * interval '1 minute' > ?", now).
+		Where("virtual_rooms.class_id IS NULL OR virtual_rooms.released_at IS NOT NULL OR virtual_rooms.class_sequence <> classes.sequence").
+		Order("classes.scheduled_at, classes.id").
+		Limit(limit).
+		Find(&classes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// GetRoomsToRelease returns up to limit rooms still held by classes that were cancelled, deleted
+// or moved offline.
+func GetRoomsToRelease(db *gorm.DB, limit int) ([]VirtualRoom, error) {
+	var rooms []VirtualRoom
+	err := db.Joins("JOIN classes ON classes.id = virtual_rooms.class_id").
+		Where("virtual_rooms.released_at IS NULL").
+		Where("classes.status = ? OR classes.deleted_at IS NOT NULL OR NOT classes.online", ClassStatusCancelled).
+		Order("virtual_rooms.class_id").
+		Limit(limit).
+		Find(&rooms).Error
+	if err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
@@ -0,0 +1,245 @@
+// Package recurrence expands the subset of RFC 5545 recurrence rules used by class series:
+// FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL, COUNT, UNTIL and BYDAY.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxSteps bounds the expansion of a rule, whatever its interval and limits.
+const maxSteps = 100000
+
+const untilLayout = "20060102T150405Z"
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a parsed RRULE. A zero Count and a nil Until leave the recurrence unbounded.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", with or without its
+// "RRULE:" prefix.
+func Parse(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(val))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL %q", ErrInvalidRule, val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("%w: COUNT %q", ErrInvalidRule, val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL %q", ErrInvalidRule, val)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: BYDAY %q", ErrInvalidRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "WKST":
+			if strings.ToUpper(val) != "MO" {
+				return nil, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+	}
+
+	switch rule.Freq {
+	case Daily, Weekly:
+	case Monthly:
+		if len(rule.ByDay) > 0 {
+			return nil, fmt.Errorf("%w: BYDAY is not supported with FREQ=MONTHLY", ErrInvalidRule)
+		}
+	default:
+		return nil, fmt.Errorf("%w: FREQ %q", ErrInvalidRule, rule.Freq)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL are exclusive", ErrInvalidRule)
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool {
+		return weekOffset(rule.ByDay[i]) < weekOffset(rule.ByDay[j])
+	})
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse(untilLayout, value); err == nil {
+		return until, nil
+	}
+	// A date alone includes the whole day.
+	day, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+// String formats the rule as an RRULE value.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			days = append(days, strings.ToUpper(weekday.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns, in order, the starts of the occurrences of a series beginning at start, up to
+// and including until. Exceptions are left out but, as in RFC 5545, still count toward COUNT.
+func (r Rule) Occurrences(start, until time.Time, exceptions []time.Time) []time.Time {
+	if r.Until != nil && r.Until.Before(until) {
+		until = *r.Until
+	}
+
+	var occurrences []time.Time
+	counted := 0
+	r.expand(start, func(occurrence time.Time) bool {
+		if occurrence.After(until) {
+			return false
+		}
+		counted++
+		if !isException(occurrence, exceptions) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return r.Count == 0 || counted < r.Count
+	})
+	return occurrences
+}
+
+// expand passes every candidate occurrence, in order, to visit until it returns false.
+func (r Rule) expand(start time.Time, visit func(time.Time) bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch r.Freq {
+	case Daily:
+		for step := 0; step < maxSteps; step += interval {
+			day := start.AddDate(0, 0, step)
+			if len(r.ByDay) > 0 && !hasWeekday(r.ByDay, day.Weekday()) {
+				continue
+			}
+			if !visit(day) {
+				return
+			}
+		}
+
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		monday := start.AddDate(0, 0, -weekOffset(start.Weekday()))
+		for week := 0; week < maxSteps; week += interval {
+			for _, weekday := range days {
+				day := monday.AddDate(0, 0, 7*week+weekOffset(weekday))
+				if day.Before(start) {
+					continue
+				}
+				if !visit(day) {
+					return
+				}
+			}
+		}
+
+	case Monthly:
+		for month := 0; month < maxSteps; month += interval {
+			day := start.AddDate(0, month, 0)
+			// Months without the start's day of month are skipped.
+			if day.Day() != start.Day() {
+				continue
+			}
+			if !visit(day) {
+				return
+			}
+		}
+	}
+}
+
+// weekOffset returns the position of a weekday in a week starting on Monday.
+func weekOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+func hasWeekday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, candidate := range weekdays {
+		if candidate == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func isException(occurrence time.Time, exceptions []time.Time) bool {
+	for _, exception := range exceptions {
+		if exception.Equal(occurrence) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package recurrence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	until := time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  *Rule
+	}{
+		{
+			name:  "weekly by day",
+			value: "FREQ=WEEKLY;BYDAY=TH,MO;COUNT=10",
+			want:  &Rule{Freq: Weekly, Interval: 1, Count: 10, ByDay: []time.Weekday{time.Monday, time.Thursday}},
+		},
+		{
+			name:  "prefix and lower case",
+			value: "RRULE:freq=daily;interval=2",
+			want:  &Rule{Freq: Daily, Interval: 2},
+		},
+		{
+			name:  "until date-time",
+			value: "FREQ=WEEKLY;UNTIL=20260331T235959Z",
+			want:  &Rule{Freq: Weekly, Interval: 1, Until: &until},
+		},
+		{
+			name:  "until date includes the whole day",
+			value: "FREQ=MONTHLY;UNTIL=20260331",
+			want:  &Rule{Freq: Monthly, Interval: 1, Until: &until},
+		},
+		{name: "missing frequency", value: "COUNT=3"},
+		{name: "unsupported frequency", value: "FREQ=YEARLY"},
+		{name: "zero interval", value: "FREQ=DAILY;INTERVAL=0"},
+		{name: "unknown weekday", value: "FREQ=WEEKLY;BYDAY=XX"},
+		{name: "count and until", value: "FREQ=DAILY;COUNT=2;UNTIL=20260331"},
+		{name: "monthly by day", value: "FREQ=MONTHLY;BYDAY=MO"},
+		{name: "unsupported part", value: "FREQ=DAILY;BYHOUR=9"},
+		{name: "malformed part", value: "FREQ=DAILY;COUNT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.value)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Fatalf("Parse(%q) error = %v, want ErrInvalidRule", tt.value, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(rule, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.value, rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	inNewYork := func(day, hour int) time.Time {
+		return time.Date(2026, 3, day, hour, 0, 0, 0, newYork)
+	}
+	inUTC := func(day, hour int) time.Time {
+		return time.Date(2026, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		rule       string
+		start      time.Time
+		until      time.Time
+		exceptions []time.Time
+		want       []time.Time
+	}{
+		{
+			// Clocks go forward on March 8: occurrences keep their wall time, not their UTC time.
+			name:  "weekly by day across a DST change",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH",
+			start: inNewYork(2, 9),
+			until: inNewYork(13, 0),
+			want:  []time.Time{inNewYork(2, 9), inNewYork(5, 9), inNewYork(9, 9), inNewYork(12, 9)},
+		},
+		{
+			name:  "until on an occurrence includes it",
+			rule:  "FREQ=DAILY;UNTIL=20260105T090000Z",
+			start: inUTC(1, 9),
+			until: inUTC(31, 0),
+			want:  []time.Time{inUTC(1, 9), inUTC(2, 9), inUTC(3, 9), inUTC(4, 9), inUTC(5, 9)},
+		},
+		{
+			name:  "until just before an occurrence excludes it",
+			rule:  "FREQ=DAILY;UNTIL=20260105T085959Z",
+			start: inUTC(1, 9),
+			until: inUTC(31, 0),
+			want:  []time.Time{inUTC(1, 9), inUTC(2, 9), inUTC(3, 9), inUTC(4, 9)},
+		},
+		{
+			name:  "window ends before the rule",
+			rule:  "FREQ=DAILY;UNTIL=20260131",
+			start: inUTC(1, 9),
+			until: inUTC(3, 9),
+			want:  []time.Time{inUTC(1, 9), inUTC(2, 9), inUTC(3, 9)},
+		},
+		{
+			name:       "exceptions count toward count",
+			rule:       "FREQ=WEEKLY;COUNT=3",
+			start:      inUTC(1, 9),
+			until:      inUTC(31, 0),
+			exceptions: []time.Time{inUTC(8, 9)},
+			want:       []time.Time{inUTC(1, 9), inUTC(15, 9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+			got := rule.Occurrences(tt.start, tt.until, tt.exceptions)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Occurrences() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
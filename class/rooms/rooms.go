@@ -0,0 +1,42 @@
+// Package rooms provisions the virtual rooms online classes are delivered in, through a pluggable
+// provider.
+package rooms
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrRoomNotFound = errors.New("room not found")
+
+// Spec describes the session a room is provisioned for.
+type Spec struct {
+	ClassID  uint
+	Title    string
+	StartsAt time.Time
+	Duration time.Duration
+	Capacity uint
+}
+
+// Room is a room as provisioned by a provider. HostURL is only shown to the instructor.
+type Room struct {
+	ID      string
+	HostURL string
+	JoinURL string
+	DialIn  string
+}
+
+// Provider creates, updates and deletes rooms on a conferencing service, and hands out the links
+// participants join with.
+type Provider interface {
+	// Name identifies the provider in the rooms it provisioned.
+	Name() string
+	CreateRoom(ctx context.Context, spec Spec) (Room, error)
+	// UpdateRoom moves or resizes a room after its class changed.
+	UpdateRoom(ctx context.Context, roomID string, spec Spec) (Room, error)
+	// DeleteRoom deletes a room. Deleting a room that no longer exists succeeds.
+	DeleteRoom(ctx context.Context, roomID string) error
+	// LearnerJoinURL returns the personal link a learner joins a room with.
+	LearnerJoinURL(room Room, learnerID uint) (string, error)
+}
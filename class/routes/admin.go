@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"class/config"
+	"class/controllers"
+	"class/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func AdminRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB, referenceService *services.ReferenceService, roomService *services.RoomService) {
+	deadLetterController := controllers.NewDeadLetterController(rabbitMQConfig)
+	referenceController := controllers.NewReferenceController(referenceService)
+	calendarController := controllers.NewCalendarController(services.NewCalendarService(db, rabbitMQConfig))
+	roomController := controllers.NewRoomController(roomService)
+
+	app.Get("/admin/dead-letters/:queue", deadLetterController.ListDeadLetters)
+	app.Post("/admin/dead-letters/:queue/replay", deadLetterController.ReplayDeadLetters)
+	app.Get("/admin/dead-letters/:queue/:eventId", deadLetterController.GetDeadLetter)
+	app.Delete("/admin/dead-letters/:queue", deadLetterController.PurgeDeadLetters)
+
+	app.Post("/admin/projections/courses/rebuild", referenceController.RebuildReferences)
+
+	app.Post("/admin/learners/:id/calendar-token", calendarController.IssueLearnerToken)
+	app.Post("/admin/classes/:id/room/host-token", roomController.IssueHostToken)
+}
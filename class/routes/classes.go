@@ -11,9 +11,23 @@ import (
 	"gorm.io/gorm"
 )
 
-func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB, writeMode config.WriteMode, seriesService *services.SeriesService, roomService *services.RoomService) {
 	classService := services.NewClassService(db, rabbitMQConfig)
-	classController := controllers.NewClassController(classService, rabbitMQConfig)
+	classController := controllers.NewClassController(classService, rabbitMQConfig, writeMode)
+	operationController := controllers.NewOperationController(classService)
+	enrollmentService := services.NewEnrollmentService(db, rabbitMQConfig)
+	enrollmentController := controllers.NewEnrollmentController(enrollmentService)
+	attendanceService := services.NewAttendanceService(db, rabbitMQConfig)
+	attendanceController := controllers.NewAttendanceController(attendanceService)
+	roomController := controllers.NewRoomController(roomService)
+	feedbackService := services.NewFeedbackService(db, rabbitMQConfig)
+	feedbackController := controllers.NewFeedbackController(feedbackService)
+	classTypeService := services.NewClassTypeService(db, rabbitMQConfig)
+	classTypeController := controllers.NewClassTypeController(classTypeService)
+	seriesController := controllers.NewSeriesController(seriesService)
+	instructorController := controllers.NewInstructorController(classService)
+	calendarService := services.NewCalendarService(db, rabbitMQConfig)
+	calendarController := controllers.NewCalendarController(calendarService)
 
 	app.Get("/classes", classController.ListClasses)
 
@@ -23,6 +37,54 @@ func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm
 
 	app.Delete("/class/:id", classController.DeleteClass)
 
+	app.Post("/class/:id/cancel", classController.CancelClass)
+	app.Post("/class/:id/reschedule", classController.RescheduleClass)
+	app.Get("/class/:id/history", classController.GetClassHistory)
+
+	app.Post("/class/:id/enrollments", enrollmentController.Enroll)
+	app.Delete("/class/:id/enrollments/:learnerId", enrollmentController.CancelEnrollment)
+
+	app.Get("/class/:id/attendance", attendanceController.GetAttendance)
+	app.Post("/class/:id/attendance", attendanceController.CheckIn)
+	app.Post("/class/:id/attendance/import", attendanceController.ImportAttendance)
+	app.Post("/class/:id/check-in-code", attendanceController.IssueCheckInCode)
+	app.Post("/class/:id/check-in", attendanceController.SelfCheckIn)
+
+	app.Get("/class/:id/room", roomController.GetRoom)
+	app.Get("/class/:id/room/join", roomController.JoinRoom)
+	app.Get("/class/:id/room/host", roomController.HostRoom)
+	app.Put("/class/:id/room/recording", roomController.SetRecording)
+
+	app.Post("/class/:id/feedback", feedbackController.SubmitFeedback)
+	app.Get("/class/:id/feedback", feedbackController.ListClassFeedback)
+	app.Get("/feedback/summary", feedbackController.GetFeedbackSummary)
+	app.Get("/feedback/flagged", feedbackController.ListFlaggedFeedback)
+	app.Post("/feedback/:id/flag", feedbackController.FlagFeedback)
+	app.Put("/feedback/:id/moderation", feedbackController.ModerateFeedback)
+
+	app.Get("/class-types", classTypeController.ListClassTypes)
+	app.Get("/class-type/:id", classTypeController.GetClassType)
+	app.Post("/class-type", classTypeController.CreateClassType)
+	app.Put("/class-type/:id", classTypeController.UpdateClassType)
+	app.Delete("/class-type/:id", classTypeController.DeleteClassType)
+
+	app.Post("/class-series", seriesController.CreateSeries)
+	app.Get("/class-series/:id", seriesController.GetSeries)
+	app.Delete("/class-series/:id", seriesController.DeleteSeries)
+	app.Put("/class-series/:id/occurrences/:classId", seriesController.UpdateOccurrence)
+	app.Delete("/class-series/:id/occurrences/:classId", seriesController.DeleteOccurrence)
+
+	app.Get("/instructors/:id/availability", instructorController.GetAvailability)
+
+	app.Get("/class/:id.ics", calendarController.ExportClass)
+	app.Get("/calendars/companies/:id.ics", calendarController.CompanyFeed)
+	app.Get("/calendars/courses/:id.ics", calendarController.CourseFeed)
+	app.Get("/calendars/instructors/:id.ics", calendarController.InstructorFeed)
+	app.Get("/calendars/learners/:id.ics", calendarController.LearnerFeed)
+
+	app.Get("/operations/:id", operationController.GetOperation)
+	app.Get("/operations/:id/stream", operationController.StreamOperation)
+
 	app.Get("/swagger/*", swagger.New(swagger.Config{
 		URL: "http://localhost:3000/docs/swagger.json",
 	}))
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"class/config"
+	"class/controllers"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func HealthRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	healthController := controllers.NewHealthController(rabbitMQConfig, db)
+
+	app.Get("/health", healthController.Health)
+	app.Get("/metrics", healthController.Metrics)
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"class/config"
+	"class/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"leecho/events"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// SelfCheckInLead is how long before a class starts its learners may check themselves in.
+	SelfCheckInLead = 15 * time.Minute
+	// MaxAttendanceImportRows bounds the rows of an imported attendee list.
+	MaxAttendanceImportRows = 10000
+)
+
+var (
+	ErrNotClassInstructor = errors.New("not the instructor of the class")
+	ErrCheckInClosed      = errors.New("check-in is closed")
+	ErrInvalidCheckInCode = errors.New("invalid check-in code")
+	ErrNotEnrolled        = errors.New("learner not enrolled")
+	ErrInvalidAttendees   = errors.New("invalid attendee list")
+)
+
+// Column names recognised in imported attendee lists, lower-cased. Webinar tools name them differently.
+var (
+	learnerColumns  = []string{"learner_id", "learner id", "user_id", "user id", "registrant id"}
+	joinedColumns   = []string{"joined_at", "join time", "check-in time", "first join"}
+	durationColumns = []string{"minutes", "duration", "duration (minutes)", "time in session (minutes)"}
+)
+
+// Time layouts accepted for join times in imported attendee lists. Those without an offset are
+// read in the time zone of the class.
+var joinedLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"01/02/2006 03:04:05 PM",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+}
+
+// CheckInWindow is the period a check-in code of a class is valid in.
+type CheckInWindow struct {
+	models.CheckInCode
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+}
+
+// ImportError reports a line of an attendee list that was not imported.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResult sums up the import of an attendee list.
+type ImportResult struct {
+	Recorded        int           `json:"recorded"`
+	AlreadyRecorded int           `json:"already_recorded"`
+	Skipped         []ImportError `json:"skipped"`
+}
+
+// AttendanceReport compares the attendees of a class with its enrolled learners. Walk-ins attended
+// without being enrolled.
+type AttendanceReport struct {
+	ClassID        uint                `json:"class_id"`
+	Enrolled       int                 `json:"enrolled"`
+	Attended       int                 `json:"attended"`
+	AttendanceRate float64             `json:"attendance_rate"`
+	Attendees      []models.Attendance `json:"attendees"`
+	NoShows        []uint              `json:"no_shows"`
+	WalkIns        []uint              `json:"walk_ins"`
+}
+
+type AttendanceService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewAttendanceService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *AttendanceService {
+	return &AttendanceService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// CheckIn records the attendance of learners on behalf of the instructor of a class, from the time
+// self check-in opens on, unless the class was cancelled. Learners already checked in keep their
+// first check-in. It returns the attendance of every learner.
+func (s *AttendanceService) CheckIn(classID, instructorID uint, learnerIDs []uint, correlationID string) ([]models.Attendance, error) {
+	attendances := make([]models.Attendance, 0, len(learnerIDs))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := instructedClass(tx, classID, instructorID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if class.Cancelled() || now.Before(class.ScheduledAt.Add(-SelfCheckInLead)) {
+			return ErrCheckInClosed
+		}
+		for _, learnerID := range learnerIDs {
+			attendance := models.Attendance{LearnerID: learnerID, Method: models.AttendanceByInstructor, CheckedInAt: now}
+			if _, err := recordAttendance(tx, class, &attendance, correlationID); err != nil {
+				return err
+			}
+			attendances = append(attendances, attendance)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attendances, nil
+}
+
+// IssueCheckInCode generates the code the learners of a class check themselves in with, replacing
+// the previous one.
+func (s *AttendanceService) IssueCheckInCode(classID, instructorID uint) (*CheckInWindow, error) {
+	class, err := instructedClass(s.DB, classID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	code, err := models.IssueCheckInCode(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckInWindow{
+		CheckInCode: *code,
+		ValidFrom:   class.ScheduledAt.Add(-SelfCheckInLead),
+		ValidUntil:  class.End(),
+	}, nil
+}
+
+// SelfCheckIn records the attendance of an enrolled learner presenting the check-in code of a
+// class while it runs. It reports whether the attendance was recorded by this call.
+func (s *AttendanceService) SelfCheckIn(classID, learnerID uint, code, correlationID string) (*models.Attendance, bool, error) {
+	var attendance *models.Attendance
+	var recorded bool
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := models.GetClassByID(tx, classID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if class.Cancelled() || now.Before(class.ScheduledAt.Add(-SelfCheckInLead)) || !now.Before(class.End()) {
+			return ErrCheckInClosed
+		}
+		checkInCode, err := models.GetCheckInCode(tx, classID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidCheckInCode
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(checkInCode.Code, strings.TrimSpace(code)) {
+			return ErrInvalidCheckInCode
+		}
+
+		enrollment, err := models.GetEnrollment(tx, classID, learnerID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		if enrollment.Status != models.EnrollmentEnrolled {
+			return ErrNotEnrolled
+		}
+
+		attendance = &models.Attendance{LearnerID: learnerID, Method: models.AttendanceSelfCheckIn, CheckedInAt: now}
+		recorded, err = recordAttendance(tx, class, attendance, correlationID)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return attendance, recorded, nil
+}
+
+// ImportAttendance records the attendees of a class from a CSV list exported by a webinar tool. The
+// list needs a header row naming a learner ID column; join time and duration columns are optional.
+// Learners joining several times are recorded once, at their first join, with their minutes summed.
+// Invalid lines are skipped and reported.
+func (s *AttendanceService) ImportAttendance(classID, instructorID uint, list io.Reader, correlationID string) (*ImportResult, error) {
+	result := &ImportResult{Skipped: []ImportError{}}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := instructedClass(tx, classID, instructorID)
+		if err != nil {
+			return err
+		}
+
+		attendees, err := parseAttendees(list, class, result)
+		if err != nil {
+			return err
+		}
+		for _, attendee := range attendees {
+			recorded, err := recordAttendance(tx, class, attendee, correlationID)
+			if err != nil {
+				return err
+			}
+			if recorded {
+				result.Recorded++
+			} else {
+				result.AlreadyRecorded++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAttendanceReport returns the attendance of a class compared with its enrolled learners.
+func (s *AttendanceService) GetAttendanceReport(classID uint) (*AttendanceReport, error) {
+	if _, err := models.GetClassByID(s.DB, classID); err != nil {
+		return nil, err
+	}
+	attendances, err := models.GetClassAttendances(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := models.GetEnrolledLearners(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AttendanceReport{
+		ClassID:   classID,
+		Enrolled:  len(enrolled),
+		Attended:  len(attendances),
+		Attendees: attendances,
+		NoShows:   []uint{},
+		WalkIns:   []uint{},
+	}
+
+	attended := make(map[uint]bool, len(attendances))
+	for _, attendance := range attendances {
+		attended[attendance.LearnerID] = true
+	}
+	isEnrolled := make(map[uint]bool, len(enrolled))
+	for _, learnerID := range enrolled {
+		isEnrolled[learnerID] = true
+		if !attended[learnerID] {
+			report.NoShows = append(report.NoShows, learnerID)
+		}
+	}
+	for _, attendance := range attendances {
+		if !isEnrolled[attendance.LearnerID] {
+			report.WalkIns = append(report.WalkIns, attendance.LearnerID)
+		}
+	}
+	if report.Enrolled > 0 {
+		report.AttendanceRate = float64(report.Enrolled-len(report.NoShows)) / float64(report.Enrolled)
+	}
+	return report, nil
+}
+
+// instructedClass returns a class taught by instructorID.
+func instructedClass(db *gorm.DB, classID, instructorID uint) (*models.Class, error) {
+	class, err := models.GetClassByID(db, classID)
+	if err != nil {
+		return nil, err
+	}
+	if class.InstructorID != instructorID {
+		return nil, ErrNotClassInstructor
+	}
+	return class, nil
+}
+
+// recordAttendance records an attendance at class and its attendance.recorded event, unless the
+// learner already attended, in which case attendance is reloaded with the recorded one.
+func recordAttendance(tx *gorm.DB, class *models.Class, attendance *models.Attendance, correlationID string) (bool, error) {
+	attendance.ClassID = class.ID
+	attendance.CourseID = class.CourseID
+	recorded, err := models.RecordAttendance(tx, attendance)
+	if err != nil {
+		return false, err
+	}
+	if !recorded {
+		existing, err := models.GetAttendance(tx, class.ID, attendance.LearnerID)
+		if err != nil {
+			return false, err
+		}
+		*attendance = *existing
+		return false, nil
+	}
+
+	event := events.New(events.AttendanceRecorded, events.SourceClassService, *attendance).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return true, models.EnqueueEvent(tx, event)
+}
+
+// parseAttendees reads the attendees of a class from a CSV list, merging the lines of a learner
+// and adding the invalid ones to result.
+func parseAttendees(list io.Reader, class *models.Class, result *ImportResult) ([]*models.Attendance, error) {
+	reader := csv.NewReader(list)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidAttendees)
+	}
+	learnerColumn := findColumn(header, learnerColumns)
+	if learnerColumn < 0 {
+		return nil, fmt.Errorf("%w: no learner ID column", ErrInvalidAttendees)
+	}
+	joinedColumn := findColumn(header, joinedColumns)
+	durationColumn := findColumn(header, durationColumns)
+
+	var attendees []*models.Attendance
+	byLearner := map[uint]*models.Attendance{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if line-1 > MaxAttendanceImportRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidAttendees, MaxAttendanceImportRows)
+		}
+		if err != nil {
+			result.Skipped = append(result.Skipped, ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		attendee, err := parseAttendee(record, class, learnerColumn, joinedColumn, durationColumn)
+		if err != nil {
+			result.Skipped = append(result.Skipped, ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		previous, ok := byLearner[attendee.LearnerID]
+		if !ok {
+			byLearner[attendee.LearnerID] = attendee
+			attendees = append(attendees, attendee)
+			continue
+		}
+		previous.Minutes += attendee.Minutes
+		if attendee.CheckedInAt.Before(previous.CheckedInAt) {
+			previous.CheckedInAt = attendee.CheckedInAt
+		}
+	}
+	return attendees, nil
+}
+
+func parseAttendee(record []string, class *models.Class, learnerColumn, joinedColumn, durationColumn int) (*models.Attendance, error) {
+	field := func(column int) string {
+		if column < 0 || column >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[column])
+	}
+
+	learnerID, err := strconv.ParseUint(field(learnerColumn), 10, 64)
+	if err != nil || learnerID == 0 {
+		return nil, errors.New("invalid learner ID")
+	}
+	attendee := &models.Attendance{LearnerID: uint(learnerID), Method: models.AttendanceImported, CheckedInAt: class.ScheduledAt}
+
+	if joined := field(joinedColumn); joined != "" {
+		checkedInAt, err := parseJoined(joined, class.Location())
+		if err != nil {
+			return nil, err
+		}
+		attendee.CheckedInAt = checkedInAt
+	}
+	if duration := field(durationColumn); duration != "" {
+		minutes, err := strconv.ParseUint(duration, 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid duration, expected minutes")
+		}
+		attendee.Minutes = uint(minutes)
+	}
+	return attendee, nil
+}
+
+func parseJoined(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range joinedLayouts {
+		if joined, err := time.ParseInLocation(layout, value, location); err == nil {
+			return joined.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid join time")
+}
+
+// findColumn returns the index of the first header matching one of names, or -1.
+func findColumn(header []string, names []string) int {
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		for _, name := range names {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"class/models"
+	"errors"
+	"time"
+)
+
+// MaxAvailabilityRange bounds the period an availability is computed for.
+const MaxAvailabilityRange = 92 * 24 * time.Hour
+
+var ErrInvalidRange = errors.New("invalid time range")
+
+// TimeBlock is a period of an instructor's schedule. Busy blocks list the classes filling them.
+type TimeBlock struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ClassIDs []uint    `json:"class_ids,omitempty"`
+}
+
+// Availability splits a period into the blocks an instructor teaches in and the free ones.
+type Availability struct {
+	InstructorID uint        `json:"instructor_id"`
+	From         time.Time   `json:"from"`
+	To           time.Time   `json:"to"`
+	Busy         []TimeBlock `json:"busy"`
+	Free         []TimeBlock `json:"free"`
+}
+
+// GetInstructorAvailability computes the busy and free blocks of an instructor in [from, to) from
+// their classes. Overlapping or adjacent classes merge into one busy block.
+func (s *ClassService) GetInstructorAvailability(instructorID uint, from, to time.Time) (*Availability, error) {
+	if !from.Before(to) || to.Sub(from) > MaxAvailabilityRange {
+		return nil, ErrInvalidRange
+	}
+
+	classes, err := models.GetInstructorClasses(s.DB, instructorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &Availability{
+		InstructorID: instructorID,
+		From:         from,
+		To:           to,
+		Busy:         []TimeBlock{},
+		Free:         []TimeBlock{},
+	}
+	for _, class := range classes {
+		start, end := class.ScheduledAt, class.End()
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		last := len(availability.Busy) - 1
+		if last >= 0 && !start.After(availability.Busy[last].End) {
+			if end.After(availability.Busy[last].End) {
+				availability.Busy[last].End = end
+			}
+			availability.Busy[last].ClassIDs = append(availability.Busy[last].ClassIDs, class.ID)
+			continue
+		}
+		availability.Busy = append(availability.Busy, TimeBlock{Start: start, End: end, ClassIDs: []uint{class.ID}})
+	}
+
+	cursor := from
+	for _, busy := range availability.Busy {
+		if cursor.Before(busy.Start) {
+			availability.Free = append(availability.Free, TimeBlock{Start: cursor, End: busy.Start})
+		}
+		cursor = busy.End
+	}
+	if cursor.Before(to) {
+		availability.Free = append(availability.Free, TimeBlock{Start: cursor, End: to})
+	}
+	return availability, nil
+}
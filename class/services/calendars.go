@@ -0,0 +1,115 @@
+package services
+
+import (
+	"class/config"
+	"class/ical"
+	"class/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// calendarLookback is how far back calendar feeds go.
+	calendarLookback = 30 * 24 * time.Hour
+	// calendarLimit bounds the events of a calendar feed.
+	calendarLimit = 1000
+)
+
+type CalendarService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewCalendarService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *CalendarService {
+	return &CalendarService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// ClassCalendar returns a calendar holding a single class, cancelled when the class was deleted.
+func (s *CalendarService) ClassCalendar(id uint) (*ical.Calendar, error) {
+	class, err := models.GetCalendarClass(s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ical.Calendar{
+		Name:   class.Title,
+		Events: []ical.Event{classEvent(*class, ical.StatusConfirmed)},
+	}, nil
+}
+
+// FeedCalendar returns the feed of the classes matching filter, from the lookback period on.
+func (s *CalendarService) FeedCalendar(name string, filter models.ClassFilter) (*ical.Calendar, error) {
+	from := time.Now().Add(-calendarLookback)
+	filter.ScheduledFrom = &from
+
+	classes, err := models.GetCalendarClasses(s.DB, filter, calendarLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &ical.Calendar{Name: name, Events: make([]ical.Event, 0, len(classes))}
+	for _, class := range classes {
+		calendar.Events = append(calendar.Events, classEvent(class, ical.StatusConfirmed))
+	}
+	return calendar, nil
+}
+
+// LearnerCalendar returns the feed of the classes of the learner a calendar token was issued to.
+// Waitlisted enrollments are tentative, cancelled ones are cancelled.
+func (s *CalendarService) LearnerCalendar(learnerID uint, token string) (*ical.Calendar, error) {
+	owner, err := models.GetCalendarTokenLearner(s.DB, token)
+	if err != nil {
+		return nil, err
+	}
+	if owner != learnerID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	classes, err := models.GetLearnerClasses(s.DB, learnerID, time.Now().Add(-calendarLookback), calendarLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &ical.Calendar{Name: "My classes", Events: make([]ical.Event, 0, len(classes))}
+	for _, class := range classes {
+		status := ical.StatusConfirmed
+		switch class.EnrollmentStatus {
+		case models.EnrollmentWaitlisted:
+			status = ical.StatusTentative
+		case models.EnrollmentCancelled:
+			status = ical.StatusCancelled
+		}
+		calendar.Events = append(calendar.Events, classEvent(class.Class, status))
+	}
+	return calendar, nil
+}
+
+// IssueLearnerToken issues the calendar token of a learner, revoking the previous one.
+func (s *CalendarService) IssueLearnerToken(learnerID uint) (*models.CalendarToken, error) {
+	return models.IssueCalendarToken(s.DB, learnerID)
+}
+
+// classEvent converts a class to a calendar event. Its UID only depends on the class ID, and
+// cancelled or deleted classes are cancelled whatever status is given.
+func classEvent(class models.Class, status string) ical.Event {
+	if class.Cancelled() || class.DeletedAt.Valid {
+		status = ical.StatusCancelled
+	}
+	return ical.Event{
+		UID:          fmt.Sprintf("class-%d@leecho", class.ID),
+		Sequence:     class.Sequence,
+		Start:        class.ScheduledAt,
+		End:          class.End(),
+		TimeZone:     class.TimeZone,
+		Summary:      class.Title,
+		Description:  class.Description,
+		Location:     class.Room,
+		Status:       status,
+		Created:      class.CreatedAt,
+		LastModified: class.UpdatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"class/config"
+	"class/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDefaultClassType  = errors.New("default class types cannot be changed")
+	ErrClassTypeInUse    = errors.New("class type still used by classes or series")
+	ErrInvalidReassignee = errors.New("invalid class type to reassign classes to")
+)
+
+type ClassTypeService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewClassTypeService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *ClassTypeService {
+	return &ClassTypeService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+func (s *ClassTypeService) GetClassTypes(companyID uint) ([]models.ClassType, error) {
+	return models.GetClassTypes(s.DB, companyID)
+}
+
+func (s *ClassTypeService) GetClassType(id uint) (*models.ClassType, error) {
+	return models.GetClassTypeByID(s.DB, id)
+}
+
+func (s *ClassTypeService) CreateClassType(classType *models.ClassType) error {
+	return models.CreateClassType(s.DB, classType)
+}
+
+// UpdateClassType replaces the name, description and defaults of a company's class type and reloads
+// it into classType.
+func (s *ClassTypeService) UpdateClassType(id uint, classType *models.ClassType) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := models.GetClassTypeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if existing.CompanyID == nil {
+			return ErrDefaultClassType
+		}
+		if err := models.UpdateClassType(tx, id, classType); err != nil {
+			return err
+		}
+		updated, err := models.GetClassTypeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		*classType = *updated
+		return nil
+	})
+}
+
+// DeleteClassType deletes a company's class type. Classes or series still using it block the
+// deletion unless reassignTo names another type available to the company, which they are moved to
+// first.
+func (s *ClassTypeService) DeleteClassType(id uint, reassignTo uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		classType, err := models.GetClassTypeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if classType.CompanyID == nil {
+			return ErrDefaultClassType
+		}
+
+		inUse, err := models.CountClassesOfType(tx, id)
+		if err != nil {
+			return err
+		}
+		if inUse > 0 {
+			if reassignTo == 0 {
+				return ErrClassTypeInUse
+			}
+			if reassignTo == id {
+				return ErrInvalidReassignee
+			}
+			target, err := models.GetClassTypeByID(tx, reassignTo)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidReassignee
+			}
+			if err != nil {
+				return err
+			}
+			if !target.AvailableTo(*classType.CompanyID) {
+				return ErrInvalidReassignee
+			}
+			if err := models.ReassignClassType(tx, id, reassignTo); err != nil {
+				return err
+			}
+		}
+
+		return models.DeleteClassType(tx, id)
+	})
+}
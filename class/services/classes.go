@@ -3,10 +3,29 @@ package services
 import (
 	"class/config"
 	"class/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"leecho/events"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// OperationPollInterval is how often a waiting client's operation is read again.
+	OperationPollInterval = 500 * time.Millisecond
+	// MaxOperationWait bounds how long a request may wait for an operation to finish.
+	MaxOperationWait = time.Minute
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type ClassService struct {
 	DB             *gorm.DB
 	rabbitMQConfig *config.RabbitMQConfig
@@ -19,16 +38,221 @@ func NewClassService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *ClassS
 	}
 }
 
-func (s *ClassService) GetAllClasses() ([]models.Class, error) {
-	classes := []models.Class{
-		{ID: 1, Title: "Kubernetes 101", Description: "From Murrica Kube isnt Kube"},
-		{ID: 2, Title: "ArgoCD", Description: "ArgoCD to Git up and go?"},
-		{ID: 3, Title: "Hashicorp Vault", Description: "Do you know the crochetage?"},
-		{ID: 4, Title: "Opentelemetry", Description: "It depends on how much technology scares you"},
+// GetAllClasses returns one page of classes matching the filter, starting after the given cursor.
+// The returned cursor is empty when there is no further page.
+func (s *ClassService) GetAllClasses(filter models.ClassFilter, cursor string, limit int) ([]models.Class, string, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	var after *models.ClassCursor
+	if cursor != "" {
+		decoded, err := decodeClassCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = decoded
+	}
+
+	// Fetch one extra row to know whether another page follows.
+	classes, err := models.GetAllClasses(s.DB, filter, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(classes) > limit {
+		classes = classes[:limit]
+		last := classes[len(classes)-1]
+		nextCursor = encodeClassCursor(models.ClassCursor{ScheduledAt: last.ScheduledAt, ID: last.ID})
+	}
+
+	return classes, nextCursor, nil
+}
+
+// CreateClass persists a class and records its class.created event in the outbox, in one transaction.
+func (s *ClassService) CreateClass(class *models.Class, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CheckReferences(tx, class.CourseID, class.InstructorID); err != nil {
+			return err
+		}
+		if err := models.CreateClass(tx, class); err != nil {
+			return err
+		}
+		persisted, err := models.GetClassByID(tx, class.ID)
+		if err != nil {
+			return err
+		}
+		*class = *persisted
+
+		event := events.New(events.ClassCreated, events.SourceClassService, *class).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// UpdateClass applies the non-zero fields of class to an existing class, reloads it into class and
+// records its class.updated event in the outbox, in one transaction. Seats added by raising the
+// capacity go to the learners waiting longest.
+func (s *ClassService) UpdateClass(id uint, class *models.Class, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CheckChangedReferences(tx, class.CourseID, class.InstructorID); err != nil {
+			return err
+		}
+		persisted, err := ApplyClassUpdate(tx, id, class, correlationID)
+		if err != nil {
+			return err
+		}
+		*class = *persisted
+
+		event := events.New(events.ClassUpdated, events.SourceClassService, *class).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// ApplyClassUpdate locks a class and updates it. When its capacity grew, waitlisted learners are
+// promoted into the new seats, recording their enrollment events. Run it inside a transaction; it
+// returns the class as persisted.
+func ApplyClassUpdate(tx *gorm.DB, id uint, changes *models.Class, correlationID string) (*models.Class, error) {
+	existing, err := models.LockClass(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := models.UpdateClass(tx, id, changes); err != nil {
+		return nil, err
+	}
+	class, err := models.GetClassByID(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if class.MaxParticipants <= existing.MaxParticipants || class.Cancelled() {
+		return class, nil
+	}
+	if err := promoteWaitlisted(tx, class, correlationID); err != nil {
+		return nil, err
+	}
+	return models.GetClassByID(tx, id)
+}
+
+// DeleteClass deletes an existing class and records its class.deleted event in the outbox, in one transaction.
+func (s *ClassService) DeleteClass(id uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetClassByID(tx, id); err != nil {
+			return err
+		}
+		if err := models.DeleteClass(tx, id); err != nil {
+			return err
+		}
+
+		event := events.New(events.ClassDeleted, events.SourceClassService, events.Deleted{ID: id}).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// RequestClassCreation records a class.created command for the consumer to apply, once its course
+// and instructor are known, its class type is available to its company and its start resolves.
+func (s *ClassService) RequestClassCreation(class models.Class, correlationID string) (*models.Operation, error) {
+	if err := models.CheckReferences(s.DB, class.CourseID, class.InstructorID); err != nil {
+		return nil, err
+	}
+	// The consumer resolves the start again, from the command as requested.
+	schedule := class
+	if err := schedule.ResolveSchedule(); err != nil {
+		return nil, err
+	}
+	if err := models.CheckClassType(s.DB, class.ClassTypeID, class.CompanyID); err != nil {
+		return nil, err
+	}
+	event := events.New(events.ClassCreated, events.SourceClassService, class).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+// RequestClassUpdate records a class.updated command for the consumer to apply, once the course and
+// instructor it changes to are known, its class type is available to its company and its start
+// resolves.
+func (s *ClassService) RequestClassUpdate(class models.Class, correlationID string) (*models.Operation, error) {
+	if err := models.CheckChangedReferences(s.DB, class.CourseID, class.InstructorID); err != nil {
+		return nil, err
+	}
+	// Missing classes are left for the consumer to report.
+	existing, err := models.GetClassByID(s.DB, class.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if existing != nil && (class.ClassTypeID != 0 || class.CompanyID != 0) {
+		if err := models.CheckClassTypeChange(s.DB, existing, &class); err != nil {
+			return nil, err
+		}
+	}
+	if existing == nil {
+		existing = &models.Class{}
+	}
+	// The consumer resolves the start again, against the class as it is then.
+	schedule := class
+	if err := schedule.ResolveScheduleChange(existing); err != nil {
+		return nil, err
+	}
+	event := events.New(events.ClassUpdated, events.SourceClassService, class).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+// RequestClassDeletion records a class.deleted command for the consumer to apply.
+func (s *ClassService) RequestClassDeletion(id uint, correlationID string) (*models.Operation, error) {
+	event := events.New(events.ClassDeleted, events.SourceClassService, events.Deleted{ID: id}).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+func (s *ClassService) GetOperation(id string) (*models.Operation, error) {
+	return models.GetOperationByID(s.DB, id)
+}
+
+// WaitForOperation returns the operation as soon as it is done, or as it stands once wait has elapsed.
+func (s *ClassService) WaitForOperation(id string, wait time.Duration) (*models.Operation, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		operation, err := models.GetOperationByID(s.DB, id)
+		if err != nil || operation.Done() || time.Now().Add(OperationPollInterval).After(deadline) {
+			return operation, err
+		}
+		time.Sleep(OperationPollInterval)
 	}
-	return classes, nil
 }
 
-func (s *ClassService) CreateClass(class *models.Class) error {
-	return models.CreateClass(s.DB, class)
+func encodeClassCursor(cursor models.ClassCursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.ScheduledAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeClassCursor(cursor string) (*models.ClassCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.ClassCursor{ScheduledAt: time.Unix(0, nanos).UTC(), ID: uint(id)}, nil
 }
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"class/config"
+	"class/models"
+	"errors"
+	"leecho/events"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadyEnrolled = errors.New("learner already enrolled")
+	ErrClassFull       = errors.New("class is full")
+)
+
+type EnrollmentService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewEnrollmentService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *EnrollmentService {
+	return &EnrollmentService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// Enroll gives a learner a seat in a class, or a place on its waitlist when the class is full and
+// has one. The class row stays locked for the whole transaction, so concurrent enrollments cannot
+// overbook it.
+func (s *EnrollmentService) Enroll(classID, learnerID uint, correlationID string) (*models.Enrollment, error) {
+	var enrollment *models.Enrollment
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := models.LockClass(tx, classID)
+		if err != nil {
+			return err
+		}
+		if class.Cancelled() {
+			return ErrClassCancelled
+		}
+
+		enrollment, err = models.GetEnrollment(tx, classID, learnerID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			enrollment = &models.Enrollment{ClassID: classID, LearnerID: learnerID}
+		} else if err != nil {
+			return err
+		}
+		if enrollment.Active() {
+			return ErrAlreadyEnrolled
+		}
+
+		now := time.Now()
+		enrollment.CancelledAt = nil
+		eventType := events.LearnerEnrolled
+		switch {
+		case class.CurrentEnrolled < class.MaxParticipants:
+			enrollment.Status = models.EnrollmentEnrolled
+			enrollment.EnrolledAt = &now
+			enrollment.WaitlistedAt = nil
+			if err := models.SetEnrolledCount(tx, classID, class.CurrentEnrolled+1); err != nil {
+				return err
+			}
+		case class.WaitlistEnabled:
+			enrollment.Status = models.EnrollmentWaitlisted
+			enrollment.EnrolledAt = nil
+			enrollment.WaitlistedAt = &now
+			eventType = events.LearnerWaitlisted
+		default:
+			return ErrClassFull
+		}
+
+		if err := models.SaveEnrollment(tx, enrollment); err != nil {
+			return err
+		}
+		return enqueueEnrollmentEvent(tx, eventType, *enrollment, correlationID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// CancelEnrollment removes a learner from a class or its waitlist. A freed seat goes to the learner
+// waiting longest, in the same transaction.
+func (s *EnrollmentService) CancelEnrollment(classID, learnerID uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := models.LockClass(tx, classID)
+		if err != nil {
+			return err
+		}
+
+		enrollment, err := models.GetEnrollment(tx, classID, learnerID)
+		if err != nil {
+			return err
+		}
+		if !enrollment.Active() {
+			return gorm.ErrRecordNotFound
+		}
+
+		heldSeat := enrollment.Status == models.EnrollmentEnrolled
+		now := time.Now()
+		enrollment.Status = models.EnrollmentCancelled
+		enrollment.CancelledAt = &now
+		if err := models.SaveEnrollment(tx, enrollment); err != nil {
+			return err
+		}
+		if err := enqueueEnrollmentEvent(tx, events.EnrollmentCancelled, *enrollment, correlationID); err != nil {
+			return err
+		}
+
+		if !heldSeat {
+			return nil
+		}
+		return fillFreedSeat(tx, class, correlationID)
+	})
+}
+
+// fillFreedSeat promotes the next waitlisted learner into the seat just freed in class, or releases
+// the seat when nobody is waiting.
+func fillFreedSeat(tx *gorm.DB, class *models.Class, correlationID string) error {
+	next, err := models.NextWaitlisted(tx, class.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if class.CurrentEnrolled == 0 {
+			return nil
+		}
+		return models.SetEnrolledCount(tx, class.ID, class.CurrentEnrolled-1)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	next.Status = models.EnrollmentEnrolled
+	next.EnrolledAt = &now
+	if err := models.SaveEnrollment(tx, next); err != nil {
+		return err
+	}
+	return enqueueEnrollmentEvent(tx, events.LearnerPromoted, *next, correlationID)
+}
+
+// promoteWaitlisted gives the free seats of class to the learners waiting longest.
+func promoteWaitlisted(tx *gorm.DB, class *models.Class, correlationID string) error {
+	promoted := false
+	for class.CurrentEnrolled < class.MaxParticipants {
+		next, err := models.NextWaitlisted(tx, class.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		next.Status = models.EnrollmentEnrolled
+		next.EnrolledAt = &now
+		if err := models.SaveEnrollment(tx, next); err != nil {
+			return err
+		}
+		if err := enqueueEnrollmentEvent(tx, events.LearnerPromoted, *next, correlationID); err != nil {
+			return err
+		}
+		class.CurrentEnrolled++
+		promoted = true
+	}
+	if !promoted {
+		return nil
+	}
+	return models.SetEnrolledCount(tx, class.ID, class.CurrentEnrolled)
+}
+
+func enqueueEnrollmentEvent(tx *gorm.DB, eventType events.Type, enrollment models.Enrollment, correlationID string) error {
+	event := events.New(eventType, events.SourceClassService, enrollment).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return models.EnqueueEvent(tx, event)
+}
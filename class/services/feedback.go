@@ -0,0 +1,197 @@
+package services
+
+import (
+	"class/config"
+	"class/models"
+	"errors"
+	"leecho/events"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+
+	// flaggedFeedbackLimit bounds the moderation queue returned at once.
+	flaggedFeedbackLimit = 100
+)
+
+var (
+	ErrInvalidRating        = errors.New("rating must be between 1 and 5")
+	ErrNotAttended          = errors.New("learner did not attend the class")
+	ErrFeedbackSubmitted    = errors.New("feedback already submitted")
+	ErrInvalidModeration    = errors.New("invalid moderation status")
+	ErrMissingFeedbackScope = errors.New("missing feedback scope")
+)
+
+// RatingSummary aggregates ratings from 1 to 5. Distribution counts each rating. NPS reads 5 as a
+// promoter and 1 to 3 as detractors, from -100 to 100; it is nil without ratings, like Average.
+type RatingSummary struct {
+	Count        int64          `json:"count"`
+	Average      *float64       `json:"average"`
+	Distribution map[uint]int64 `json:"distribution"`
+	NPS          *float64       `json:"nps"`
+}
+
+// FeedbackSummary aggregates the ratings given to classes and to their instructors.
+type FeedbackSummary struct {
+	Rating           RatingSummary `json:"rating"`
+	InstructorRating RatingSummary `json:"instructor_rating"`
+}
+
+type FeedbackService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewFeedbackService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *FeedbackService {
+	return &FeedbackService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// SubmitFeedback records the feedback of a learner who attended a class, once, and its
+// feedback.submitted event, in one transaction.
+func (s *FeedbackService) SubmitFeedback(classID uint, feedback *models.Feedback, correlationID string) error {
+	if !validRating(feedback.Rating) || (feedback.InstructorRating != nil && !validRating(*feedback.InstructorRating)) {
+		return ErrInvalidRating
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := models.GetClassByID(tx, classID)
+		if err != nil {
+			return err
+		}
+		_, err = models.GetAttendance(tx, classID, feedback.LearnerID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotAttended
+		}
+		if err != nil {
+			return err
+		}
+		exists, err := models.FeedbackExists(tx, classID, feedback.LearnerID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrFeedbackSubmitted
+		}
+
+		feedback.ID = 0
+		feedback.ClassID = classID
+		feedback.CourseID = class.CourseID
+		feedback.ClassTypeID = class.ClassTypeID
+		feedback.InstructorID = class.InstructorID
+		feedback.ModerationStatus = models.FeedbackVisible
+		feedback.FlagReason = ""
+		if err := models.CreateFeedback(tx, feedback); err != nil {
+			return err
+		}
+
+		event := events.New(events.FeedbackSubmitted, events.SourceClassService, *feedback).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// GetClassFeedback returns the feedback of a class moderation did not hide.
+func (s *FeedbackService) GetClassFeedback(classID uint) ([]models.Feedback, error) {
+	if _, err := models.GetClassByID(s.DB, classID); err != nil {
+		return nil, err
+	}
+	return models.GetClassFeedback(s.DB, classID)
+}
+
+// Summarize aggregates the feedback matching filter, which must name a class, course, class type
+// or instructor.
+func (s *FeedbackService) Summarize(filter models.FeedbackFilter) (*FeedbackSummary, error) {
+	if filter == (models.FeedbackFilter{}) {
+		return nil, ErrMissingFeedbackScope
+	}
+
+	ratings, err := models.CountRatings(s.DB, filter, false)
+	if err != nil {
+		return nil, err
+	}
+	instructorRatings, err := models.CountRatings(s.DB, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	return &FeedbackSummary{
+		Rating:           summarizeRatings(ratings),
+		InstructorRating: summarizeRatings(instructorRatings),
+	}, nil
+}
+
+// FlagFeedback flags feedback for a moderator to review, with the reason given.
+func (s *FeedbackService) FlagFeedback(id uint, reason string) (*models.Feedback, error) {
+	feedback, err := models.GetFeedbackByID(s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	// Moderators have the last word on hidden feedback.
+	if feedback.ModerationStatus == models.FeedbackHidden {
+		return feedback, nil
+	}
+	if err := models.ModerateFeedback(s.DB, id, models.FeedbackFlagged, reason); err != nil {
+		return nil, err
+	}
+	return models.GetFeedbackByID(s.DB, id)
+}
+
+// ModerateFeedback shows or hides feedback, settling any flag.
+func (s *FeedbackService) ModerateFeedback(id uint, status string) (*models.Feedback, error) {
+	if status != models.FeedbackVisible && status != models.FeedbackHidden {
+		return nil, ErrInvalidModeration
+	}
+	feedback, err := models.GetFeedbackByID(s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := models.ModerateFeedback(s.DB, id, status, feedback.FlagReason); err != nil {
+		return nil, err
+	}
+	return models.GetFeedbackByID(s.DB, id)
+}
+
+// GetFlaggedFeedback returns the feedback awaiting moderation, oldest flag first.
+func (s *FeedbackService) GetFlaggedFeedback() ([]models.Feedback, error) {
+	return models.GetFlaggedFeedback(s.DB, flaggedFeedbackLimit)
+}
+
+func validRating(rating uint) bool {
+	return rating >= MinRating && rating <= MaxRating
+}
+
+func summarizeRatings(counts []models.RatingCount) RatingSummary {
+	summary := RatingSummary{Distribution: map[uint]int64{}}
+	for rating := uint(MinRating); rating <= MaxRating; rating++ {
+		summary.Distribution[rating] = 0
+	}
+
+	var total, promoters, detractors int64
+	for _, count := range counts {
+		summary.Distribution[count.Rating] = count.Count
+		summary.Count += count.Count
+		total += int64(count.Rating) * count.Count
+		switch {
+		case count.Rating == MaxRating:
+			promoters += count.Count
+		case count.Rating <= 3:
+			detractors += count.Count
+		}
+	}
+	if summary.Count == 0 {
+		return summary
+	}
+
+	average := math.Round(float64(total)/float64(summary.Count)*100) / 100
+	nps := math.Round(float64(promoters-detractors)/float64(summary.Count)*1000) / 10
+	summary.Average = &average
+	summary.NPS = &nps
+	return summary
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"class/models"
+	"class/recurrence"
+	"encoding/json"
+	"fmt"
+	"leecho/events"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// courseServiceTimeout bounds each request to the course service.
+	courseServiceTimeout = 30 * time.Second
+
+	// CourseDeletedReason is the reason recorded on classes cancelled with their course.
+	CourseDeletedReason = "Course deleted"
+)
+
+// RemoteCourse is a course as published by the course service, with the instructors teaching it
+// and its sub-courses.
+type RemoteCourse struct {
+	ID             uint               `json:"id"`
+	Title          string             `json:"title"`
+	ParentCourseID *uint              `json:"parent_course_id"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	Instructors    []RemoteInstructor `json:"instructors"`
+	SubCourses     []RemoteCourse     `json:"sub_courses"`
+}
+
+// RemoteInstructor is an instructor as published by the course service.
+type RemoteInstructor struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReferenceService struct {
+	DB               *gorm.DB
+	courseServiceURL string
+	client           *http.Client
+}
+
+func NewReferenceService(db *gorm.DB, courseServiceURL string) *ReferenceService {
+	return &ReferenceService{
+		DB:               db,
+		courseServiceURL: courseServiceURL,
+		client:           &http.Client{Timeout: courseServiceTimeout},
+	}
+}
+
+// Rebuild replaces the known courses and instructors with those the course service lists, in one
+// transaction. Classes already pointing at courses it no longer knows are left as they are. It
+// returns how many courses are known afterwards.
+func (s *ReferenceService) Rebuild() (int, error) {
+	var courses []RemoteCourse
+	cursor := ""
+	for {
+		query := url.Values{"limit": {"100"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page struct {
+			Data       []RemoteCourse `json:"data"`
+			NextCursor string         `json:"next_cursor"`
+		}
+		if err := s.fetch("/courses?"+query.Encode(), &page); err != nil {
+			return 0, err
+		}
+		courses = append(courses, page.Data...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	// Listings stop at the first level of sub-courses.
+	for i := range courses {
+		if err := s.fetchSubCourses(&courses[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	known := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE course_references, instructor_references IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := models.ClearReferences(tx); err != nil {
+			return err
+		}
+		for _, course := range courses {
+			saved, err := SaveCourse(tx, course)
+			if err != nil {
+				return err
+			}
+			known += saved
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return known, nil
+}
+
+// SaveCourse records a course, its sub-courses and their instructors as known. It returns how many
+// courses it recorded.
+func SaveCourse(tx *gorm.DB, course RemoteCourse) (int, error) {
+	err := models.SaveCourseReference(tx, &models.CourseReference{
+		ID:             course.ID,
+		Title:          course.Title,
+		ParentCourseID: course.ParentCourseID,
+		UpdatedAt:      course.UpdatedAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, instructor := range course.Instructors {
+		err := models.SaveInstructorReference(tx, &models.InstructorReference{
+			ID:        instructor.ID,
+			Name:      instructor.Name,
+			UpdatedAt: instructor.UpdatedAt,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	saved := 1
+	for _, subCourse := range course.SubCourses {
+		if subCourse.ParentCourseID == nil {
+			subCourse.ParentCourseID = &course.ID
+		}
+		count, err := SaveCourse(tx, subCourse)
+		if err != nil {
+			return 0, err
+		}
+		saved += count
+	}
+	return saved, nil
+}
+
+// ForgetCourse marks a deleted course and its sub-courses unknown. Their classes are flagged, and
+// those yet to start are cancelled, releasing their learners. Their series stop repeating.
+func ForgetCourse(tx *gorm.DB, courseID uint, correlationID string) error {
+	now := time.Now()
+	courseIDs, err := models.DeleteCourseReference(tx, courseID, now)
+	if err != nil {
+		return err
+	}
+
+	series, err := models.GetCourseSeries(tx, courseIDs)
+	if err != nil {
+		return err
+	}
+	for i := range series {
+		if err := endSeries(tx, &series[i], now, correlationID); err != nil {
+			return err
+		}
+	}
+
+	classes, err := models.GetCourseClasses(tx, courseIDs)
+	if err != nil {
+		return err
+	}
+	for _, class := range classes {
+		if class.Cancelled() || !class.ScheduledAt.After(now) {
+			continue
+		}
+		if _, err := cancelClass(tx, class.ID, CourseDeletedReason, correlationID); err != nil {
+			return err
+		}
+	}
+	return models.FlagCourseDeleted(tx, courseIDs, now)
+}
+
+// endSeries stops a series from repeating from at on. The classes it materialised are kept.
+func endSeries(tx *gorm.DB, series *models.ClassSeries, at time.Time, correlationID string) error {
+	rule, err := recurrence.Parse(series.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+	if rule.Until != nil && rule.Until.Before(at) {
+		return nil
+	}
+	head, _, err := splitRule(series, at)
+	if err != nil {
+		return err
+	}
+	if head == nil {
+		// Nothing occurs before at: the rule ends before the series starts.
+		until := series.Start().Add(-time.Second)
+		head = rule
+		head.Count = 0
+		head.Until = &until
+	}
+
+	series.RecurrenceRule = head.String()
+	if err := models.SaveSeries(tx, series); err != nil {
+		return err
+	}
+	return enqueueSeriesEvent(tx, events.ClassSeriesUpdated, *series, correlationID)
+}
+
+// fetchSubCourses reads the sub-courses below those listed with course, course by course.
+func (s *ReferenceService) fetchSubCourses(course *RemoteCourse) error {
+	for i := range course.SubCourses {
+		subCourse := &course.SubCourses[i]
+		var detailed RemoteCourse
+		if err := s.fetch(fmt.Sprintf("/course/%d", subCourse.ID), &detailed); err != nil {
+			return err
+		}
+		subCourse.SubCourses = detailed.SubCourses
+		if err := s.fetchSubCourses(subCourse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch reads a JSON resource of the course service into target.
+func (s *ReferenceService) fetch(path string, target interface{}) error {
+	response, err := s.client.Get(s.courseServiceURL + path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("reading %s: course service answered %s", path, response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
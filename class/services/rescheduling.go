@@ -0,0 +1,232 @@
+package services
+
+import (
+	"class/models"
+	"errors"
+	"leecho/events"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrClassCancelled = errors.New("class is cancelled")
+
+// ClassCancellation is the payload of class.cancelled: the cancelled class and the enrollments it
+// released.
+type ClassCancellation struct {
+	Class       models.Class        `json:"class"`
+	Reason      string              `json:"reason"`
+	Enrollments []models.Enrollment `json:"enrollments"`
+}
+
+// ClassRescheduling is the payload of class.rescheduled: the class in its new slot, the learners
+// moving with it and those released because they could not.
+type ClassRescheduling struct {
+	Class               models.Class        `json:"class"`
+	PreviousScheduledAt time.Time           `json:"previous_scheduled_at"`
+	PreviousDuration    uint                `json:"previous_duration"`
+	Reason              string              `json:"reason"`
+	Moved               []models.Enrollment `json:"moved"`
+	Released            []models.Enrollment `json:"released"`
+}
+
+// CancelClass cancels a class, releasing every enrollment and waitlist place, and records it in the
+// history of the class with its class.cancelled event, in one transaction.
+func (s *ClassService) CancelClass(id uint, reason, correlationID string) (*ClassCancellation, error) {
+	var cancellation *ClassCancellation
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		cancellation, err = cancelClass(tx, id, reason, correlationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+// RescheduleClass moves a class to the start and duration of slot, given as a local time in its
+// time zone or as an instant. Enrolled and waitlisted learners move with the class, except those
+// enrolled in another class at the new time, or all of them when releaseAll is set, who are
+// released. Seats they free go to the learners still waiting. The change is recorded in the
+// history of the class with its class.rescheduled event, in one transaction.
+func (s *ClassService) RescheduleClass(id uint, slot models.Class, reason string, releaseAll bool, correlationID string) (*ClassRescheduling, error) {
+	var rescheduling *ClassRescheduling
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		class, err := models.LockClass(tx, id)
+		if err != nil {
+			return err
+		}
+		if class.Cancelled() {
+			return ErrClassCancelled
+		}
+
+		changes := models.Class{
+			ScheduledAt: slot.ScheduledAt,
+			LocalStart:  slot.LocalStart,
+			TimeZone:    slot.TimeZone,
+			Duration:    slot.Duration,
+			Detached:    class.SeriesID != nil,
+		}
+		if err := models.UpdateClass(tx, id, &changes); err != nil {
+			return err
+		}
+		rescheduled, err := models.GetClassByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		released, err := releaseEnrollments(tx, rescheduled, releaseAll)
+		if err != nil {
+			return err
+		}
+		if !releaseAll {
+			if err := promoteWaitlisted(tx, rescheduled, correlationID); err != nil {
+				return err
+			}
+		}
+		moved, err := models.GetActiveEnrollments(tx, id)
+		if err != nil {
+			return err
+		}
+
+		scheduledAt := rescheduled.ScheduledAt
+		err = models.RecordClassChange(tx, &models.ClassChange{
+			ClassID:             id,
+			Action:              models.ClassChangeRescheduled,
+			Reason:              reason,
+			PreviousScheduledAt: class.ScheduledAt,
+			PreviousDuration:    class.Duration,
+			ScheduledAt:         &scheduledAt,
+			Duration:            rescheduled.Duration,
+		})
+		if err != nil {
+			return err
+		}
+
+		rescheduling = &ClassRescheduling{
+			Class:               *rescheduled,
+			PreviousScheduledAt: class.ScheduledAt,
+			PreviousDuration:    class.Duration,
+			Reason:              reason,
+			Moved:               moved,
+			Released:            released,
+		}
+		event := events.New(events.ClassRescheduled, events.SourceClassService, *rescheduling).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rescheduling, nil
+}
+
+// GetClassHistory returns the cancellations and reschedulings of a class, oldest first.
+func (s *ClassService) GetClassHistory(id uint) ([]models.ClassChange, error) {
+	if _, err := models.GetCalendarClass(s.DB, id); err != nil {
+		return nil, err
+	}
+	return models.GetClassChanges(s.DB, id)
+}
+
+// cancelClass cancels a class within tx; see CancelClass.
+func cancelClass(tx *gorm.DB, id uint, reason, correlationID string) (*ClassCancellation, error) {
+	class, err := models.LockClass(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if class.Cancelled() {
+		return nil, ErrClassCancelled
+	}
+
+	now := time.Now()
+	released, err := models.GetActiveEnrollments(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range released {
+		released[i].Status = models.EnrollmentCancelled
+		released[i].CancelledAt = &now
+		if err := models.SaveEnrollment(tx, &released[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := models.CancelClass(tx, id, now); err != nil {
+		return nil, err
+	}
+	err = models.RecordClassChange(tx, &models.ClassChange{
+		ClassID:             id,
+		Action:              models.ClassChangeCancelled,
+		Reason:              reason,
+		PreviousScheduledAt: class.ScheduledAt,
+		PreviousDuration:    class.Duration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := models.GetClassByID(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	cancellation := &ClassCancellation{Class: *cancelled, Reason: reason, Enrollments: released}
+	event := events.New(events.ClassCancelled, events.SourceClassService, *cancellation).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	if err := models.EnqueueEvent(tx, event); err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+// releaseEnrollments cancels the enrollments of a rescheduled class whose learners are busy at its
+// new time, or all of them, and updates its count of seats taken. It returns the released ones.
+func releaseEnrollments(tx *gorm.DB, class *models.Class, all bool) ([]models.Enrollment, error) {
+	enrollments, err := models.GetActiveEnrollments(tx, class.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	release := map[uint]bool{}
+	if all {
+		for _, enrollment := range enrollments {
+			release[enrollment.LearnerID] = true
+		}
+	} else {
+		learners := make([]uint, 0, len(enrollments))
+		for _, enrollment := range enrollments {
+			learners = append(learners, enrollment.LearnerID)
+		}
+		busy, err := models.GetBusyLearners(tx, class.ID, learners, class.ScheduledAt, class.End())
+		if err != nil {
+			return nil, err
+		}
+		for _, learnerID := range busy {
+			release[learnerID] = true
+		}
+	}
+
+	now := time.Now()
+	released := []models.Enrollment{}
+	for _, enrollment := range enrollments {
+		if !release[enrollment.LearnerID] {
+			continue
+		}
+		if enrollment.Status == models.EnrollmentEnrolled && class.CurrentEnrolled > 0 {
+			class.CurrentEnrolled--
+		}
+		enrollment.Status = models.EnrollmentCancelled
+		enrollment.CancelledAt = &now
+		if err := models.SaveEnrollment(tx, &enrollment); err != nil {
+			return nil, err
+		}
+		released = append(released, enrollment)
+	}
+	if len(released) == 0 {
+		return released, nil
+	}
+	return released, models.SetEnrolledCount(tx, class.ID, class.CurrentEnrolled)
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"class/models"
+	"class/rooms"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// roomSyncInterval is how often virtual rooms are brought in line with their classes.
+	roomSyncInterval = 30 * time.Second
+	// roomSyncBatchSize bounds the rooms provisioned or released per sync.
+	roomSyncBatchSize = 100
+	// roomProviderTimeout bounds each call to the virtual room provider.
+	roomProviderTimeout = 10 * time.Second
+)
+
+var ErrClassNotOnline = errors.New("class is not held online")
+
+// JoinLink is what an enrolled learner joins an online class with.
+type JoinLink struct {
+	ClassID      uint      `json:"class_id"`
+	StartsAt     time.Time `json:"starts_at"`
+	JoinURL      string    `json:"join_url"`
+	DialIn       string    `json:"dial_in"`
+	RecordingURL string    `json:"recording_url,omitempty"`
+}
+
+// HostLink is what the instructor of an online class hosts it with.
+type HostLink struct {
+	ClassID  uint      `json:"class_id"`
+	StartsAt time.Time `json:"starts_at"`
+	HostURL  string    `json:"host_url"`
+	DialIn   string    `json:"dial_in"`
+}
+
+type RoomService struct {
+	DB       *gorm.DB
+	provider rooms.Provider
+}
+
+func NewRoomService(db *gorm.DB, provider rooms.Provider) *RoomService {
+	return &RoomService{
+		DB:       db,
+		provider: provider,
+	}
+}
+
+// StartRoomSync provisions the rooms of online classes as they are created or rescheduled, and
+// releases them once the classes are cancelled or deleted, in the background. Failed calls to the
+// provider are retried on the next sync.
+func (s *RoomService) StartRoomSync() {
+	go func() {
+		ticker := time.NewTicker(roomSyncInterval)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			if err := s.syncRooms(); err != nil {
+				log.Printf("Failed to sync virtual rooms: %s", err)
+			}
+		}
+	}()
+}
+
+// GetRoom returns the room of a class to its instructor, without its host link. The room is
+// provisioned on the spot when the sync has not got to it yet.
+func (s *RoomService) GetRoom(classID, instructorID uint) (*models.VirtualRoom, error) {
+	class, err := instructedClass(s.DB, classID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ensureRoom(class)
+}
+
+// IssueHostToken issues the host token of an online class to its current instructor, revoking the
+// previous one.
+func (s *RoomService) IssueHostToken(classID uint) (*models.RoomHostToken, error) {
+	class, err := models.GetClassByID(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	if !class.Online {
+		return nil, ErrClassNotOnline
+	}
+	return models.IssueRoomHostToken(s.DB, classID, class.InstructorID)
+}
+
+// HostRoom returns the host link of a class against its host token, as long as the token was
+// issued to the instructor still teaching the class.
+func (s *RoomService) HostRoom(classID uint, token string) (*HostLink, error) {
+	hostToken, err := models.GetRoomHostToken(s.DB, token)
+	if err != nil {
+		return nil, err
+	}
+	class, err := models.GetClassByID(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	if hostToken.ClassID != classID || hostToken.InstructorID != class.InstructorID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if class.Cancelled() {
+		return nil, ErrClassCancelled
+	}
+
+	room, err := s.ensureRoom(class)
+	if err != nil {
+		return nil, err
+	}
+	return &HostLink{
+		ClassID:  classID,
+		StartsAt: class.ScheduledAt,
+		HostURL:  room.HostURL,
+		DialIn:   room.DialIn,
+	}, nil
+}
+
+// JoinRoom returns the personal join link of a learner holding a seat in an online class.
+func (s *RoomService) JoinRoom(classID, learnerID uint) (*JoinLink, error) {
+	class, err := models.GetClassByID(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	enrollment, err := models.GetEnrollment(s.DB, classID, learnerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Status != models.EnrollmentEnrolled {
+		return nil, ErrNotEnrolled
+	}
+	if class.Cancelled() {
+		return nil, ErrClassCancelled
+	}
+
+	room, err := s.ensureRoom(class)
+	if err != nil {
+		return nil, err
+	}
+	joinURL, err := s.provider.LearnerJoinURL(providerRoom(room), learnerID)
+	if err != nil {
+		return nil, err
+	}
+	return &JoinLink{
+		ClassID:      classID,
+		StartsAt:     class.ScheduledAt,
+		JoinURL:      joinURL,
+		DialIn:       room.DialIn,
+		RecordingURL: room.RecordingURL,
+	}, nil
+}
+
+// SetRecording stores the link to the recording of a class, as its instructor.
+func (s *RoomService) SetRecording(classID, instructorID uint, recordingURL string) (*models.VirtualRoom, error) {
+	class, err := instructedClass(s.DB, classID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	if !class.Online {
+		return nil, ErrClassNotOnline
+	}
+	room, err := models.GetVirtualRoom(s.DB, classID)
+	if err != nil {
+		return nil, err
+	}
+	room.RecordingURL = recordingURL
+	if err := models.SaveVirtualRoom(s.DB, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (s *RoomService) syncRooms() error {
+	classes, err := models.GetClassesNeedingRooms(s.DB, time.Now(), roomSyncBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range classes {
+		if _, err := s.provisionRoom(&classes[i]); err != nil {
+			log.Printf("Failed to provision the virtual room of class %d: %s", classes[i].ID, err)
+		}
+	}
+
+	released, err := models.GetRoomsToRelease(s.DB, roomSyncBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range released {
+		if err := s.releaseRoom(&released[i]); err != nil {
+			log.Printf("Failed to release the virtual room of class %d: %s", released[i].ClassID, err)
+		}
+	}
+	return nil
+}
+
+// ensureRoom returns the room of an online class, provisioning or updating it first when it lags
+// behind the class. Cancelled classes keep the room they had, released.
+func (s *RoomService) ensureRoom(class *models.Class) (*models.VirtualRoom, error) {
+	if !class.Online {
+		return nil, ErrClassNotOnline
+	}
+	room, err := models.GetVirtualRoom(s.DB, class.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && (class.Cancelled() || (room.ReleasedAt == nil && room.ClassSequence == class.Sequence)) {
+		return room, nil
+	}
+	if class.Cancelled() {
+		return nil, ErrClassCancelled
+	}
+	return s.provisionRoom(class)
+}
+
+// provisionRoom creates the room of a class, or updates it when the class changed since.
+func (s *RoomService) provisionRoom(class *models.Class) (*models.VirtualRoom, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), roomProviderTimeout)
+	defer cancel()
+
+	spec := rooms.Spec{
+		ClassID:  class.ID,
+		Title:    class.Title,
+		StartsAt: class.ScheduledAt,
+		Duration: time.Duration(class.Duration) * time.Minute,
+		Capacity: class.MaxParticipants,
+	}
+
+	room, err := models.GetVirtualRoom(s.DB, class.ID)
+	var provisioned rooms.Room
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		room = &models.VirtualRoom{ClassID: class.ID}
+		provisioned, err = s.provider.CreateRoom(ctx, spec)
+	case err != nil:
+		return nil, err
+	case room.ReleasedAt != nil || room.Provider != s.provider.Name():
+		provisioned, err = s.provider.CreateRoom(ctx, spec)
+	default:
+		provisioned, err = s.provider.UpdateRoom(ctx, room.RoomID, spec)
+		if errors.Is(err, rooms.ErrRoomNotFound) {
+			provisioned, err = s.provider.CreateRoom(ctx, spec)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	room.Provider = s.provider.Name()
+	room.RoomID = provisioned.ID
+	room.HostURL = provisioned.HostURL
+	room.JoinURL = provisioned.JoinURL
+	room.DialIn = provisioned.DialIn
+	room.ClassSequence = class.Sequence
+	room.ReleasedAt = nil
+	if err := models.SaveVirtualRoom(s.DB, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (s *RoomService) releaseRoom(room *models.VirtualRoom) error {
+	ctx, cancel := context.WithTimeout(context.Background(), roomProviderTimeout)
+	defer cancel()
+
+	if room.Provider == s.provider.Name() {
+		if err := s.provider.DeleteRoom(ctx, room.RoomID); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	room.ReleasedAt = &now
+	return models.SaveVirtualRoom(s.DB, room)
+}
+
+func providerRoom(room *models.VirtualRoom) rooms.Room {
+	return rooms.Room{
+		ID:      room.RoomID,
+		HostURL: room.HostURL,
+		JoinURL: room.JoinURL,
+		DialIn:  room.DialIn,
+	}
+}
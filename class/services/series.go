@@ -0,0 +1,489 @@
+package services
+
+import (
+	"class/config"
+	"class/models"
+	"class/recurrence"
+	"errors"
+	"leecho/events"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EditScope tells which occurrences of a series an edit applies to.
+type EditScope string
+
+const (
+	ScopeThis      EditScope = "this"
+	ScopeFollowing EditScope = "following"
+)
+
+const seriesMaterializeInterval = time.Hour
+
+var ErrInvalidScope = errors.New("invalid edit scope")
+
+type SeriesService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+	horizon        time.Duration
+}
+
+func NewSeriesService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig, horizon time.Duration) *SeriesService {
+	return &SeriesService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+		horizon:        horizon,
+	}
+}
+
+// CreateSeries persists a series and materialises its occurrences within the horizon, in one transaction.
+func (s *SeriesService) CreateSeries(series *models.ClassSeries, correlationID string) error {
+	rule, err := recurrence.Parse(series.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+	series.ID = 0
+	series.RecurrenceRule = rule.String()
+	series.MaterializedUntil = nil
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CheckReferences(tx, series.CourseID, series.InstructorID); err != nil {
+			return err
+		}
+		classType, err := models.GetClassTypeByID(tx, series.ClassTypeID)
+		if err != nil {
+			return err
+		}
+		if !classType.AvailableTo(series.CompanyID) {
+			return models.ErrClassTypeUnavailable
+		}
+
+		if err := models.CreateSeries(tx, series); err != nil {
+			return err
+		}
+		if err := enqueueSeriesEvent(tx, events.ClassSeriesCreated, *series, correlationID); err != nil {
+			return err
+		}
+		return materialize(tx, series, time.Now().Add(s.horizon), correlationID)
+	})
+}
+
+// GetSeries returns a series with the classes materialised from it.
+func (s *SeriesService) GetSeries(id uint) (*models.ClassSeries, []models.Class, error) {
+	series, err := models.GetSeriesByID(s.DB, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	classes, err := models.GetSeriesClasses(s.DB, id, time.Time{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return series, classes, nil
+}
+
+// UpdateOccurrence applies the non-zero fields of changes to one class of a series. With ScopeThis,
+// only that class changes and it stops following the series. With ScopeFollowing, the series is
+// split at the class, and the changes go to the new series and to the classes from the class on
+// that were not edited on their own; their schedule cannot change this way. It returns the series
+// the class belongs to afterwards.
+func (s *SeriesService) UpdateOccurrence(seriesID, classID uint, scope EditScope, changes models.Class, correlationID string) (*models.ClassSeries, error) {
+	changes = occurrenceChanges(changes)
+
+	var target *models.ClassSeries
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		series, class, err := lockOccurrence(tx, seriesID, classID)
+		if err != nil {
+			return err
+		}
+		if err := models.CheckChangedReferences(tx, changes.CourseID, changes.InstructorID); err != nil {
+			return err
+		}
+		if changes.ClassTypeID != 0 || changes.CompanyID != 0 {
+			if err := models.CheckClassTypeChange(tx, class, &changes); err != nil {
+				return err
+			}
+		}
+
+		switch scope {
+		case ScopeThis:
+			target = series
+			changes.Detached = true
+			return updateSeriesClass(tx, class.ID, &changes, correlationID)
+
+		case ScopeFollowing:
+			target, err = splitSeries(tx, series, *class.OccurrenceAt, correlationID)
+			if err != nil {
+				return err
+			}
+			changes.ScheduledAt = time.Time{}
+			changes.TimeZone = ""
+			changes.LocalStart = ""
+			applyTemplateChanges(target, changes)
+			if err := models.SaveSeries(tx, target); err != nil {
+				return err
+			}
+			eventType := events.ClassSeriesUpdated
+			if target.ID != series.ID {
+				eventType = events.ClassSeriesCreated
+			}
+			if err := enqueueSeriesEvent(tx, eventType, *target, correlationID); err != nil {
+				return err
+			}
+
+			following, err := models.GetSeriesClasses(tx, target.ID, *class.OccurrenceAt)
+			if err != nil {
+				return err
+			}
+			for _, occurrence := range following {
+				if occurrence.Detached {
+					continue
+				}
+				occurrenceChanges := changes
+				if err := updateSeriesClass(tx, occurrence.ID, &occurrenceChanges, correlationID); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		default:
+			return ErrInvalidScope
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// DeleteOccurrence deletes one class of a series. With ScopeThis, its occurrence becomes an exception
+// of the series. With ScopeFollowing, the series ends before the class and the classes from the
+// class on are deleted too.
+func (s *SeriesService) DeleteOccurrence(seriesID, classID uint, scope EditScope, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		series, class, err := lockOccurrence(tx, seriesID, classID)
+		if err != nil {
+			return err
+		}
+
+		switch scope {
+		case ScopeThis:
+			if err := deleteSeriesClass(tx, class.ID, correlationID); err != nil {
+				return err
+			}
+			series.ExceptionDates = append(series.ExceptionDates, *class.OccurrenceAt)
+			if err := models.SaveSeries(tx, series); err != nil {
+				return err
+			}
+			return enqueueSeriesEvent(tx, events.ClassSeriesUpdated, *series, correlationID)
+
+		case ScopeFollowing:
+			head, _, err := splitRule(series, *class.OccurrenceAt)
+			if err != nil {
+				return err
+			}
+			if head == nil {
+				return deleteSeries(tx, series, correlationID)
+			}
+
+			following, err := models.GetSeriesClasses(tx, series.ID, *class.OccurrenceAt)
+			if err != nil {
+				return err
+			}
+			for _, occurrence := range following {
+				if err := deleteSeriesClass(tx, occurrence.ID, correlationID); err != nil {
+					return err
+				}
+			}
+			series.RecurrenceRule = head.String()
+			series.ExceptionDates = exceptionsBetween(series.ExceptionDates, time.Time{}, *class.OccurrenceAt)
+			if err := models.SaveSeries(tx, series); err != nil {
+				return err
+			}
+			return enqueueSeriesEvent(tx, events.ClassSeriesUpdated, *series, correlationID)
+
+		default:
+			return ErrInvalidScope
+		}
+	})
+}
+
+// DeleteSeries deletes a series and every class materialised from it.
+func (s *SeriesService) DeleteSeries(id uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		series, err := models.LockSeries(tx, id)
+		if err != nil {
+			return err
+		}
+		return deleteSeries(tx, series, correlationID)
+	})
+}
+
+// StartMaterializer keeps the occurrences of every series materialised up to the horizon, in the background.
+func (s *SeriesService) StartMaterializer() {
+	go func() {
+		ticker := time.NewTicker(seriesMaterializeInterval)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			if err := s.materializeAll(); err != nil {
+				log.Printf("Failed to materialise class series: %s", err)
+			}
+		}
+	}()
+}
+
+func (s *SeriesService) materializeAll() error {
+	all, err := models.GetAllSeries(s.DB)
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(s.horizon)
+	for _, series := range all {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			locked, err := models.LockSeries(tx, series.ID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// The series was deleted since it was listed.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return materialize(tx, locked, until, "")
+		})
+		if err != nil {
+			log.Printf("Failed to materialise class series %d: %s", series.ID, err)
+		}
+	}
+	return nil
+}
+
+// materialize creates the classes of the occurrences of a series up to until that were not
+// materialised yet. Occurrences clashing with other classes are skipped and become exception dates
+// of the series, published with its class_series.updated event, so that the others still are.
+func materialize(tx *gorm.DB, series *models.ClassSeries, until time.Time, correlationID string) error {
+	if series.MaterializedUntil != nil && !series.MaterializedUntil.Before(until) {
+		return nil
+	}
+	rule, err := recurrence.Parse(series.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+
+	skipped := false
+	for _, at := range rule.Occurrences(series.Start(), until, series.ExceptionDates) {
+		if series.MaterializedUntil != nil && !at.After(*series.MaterializedUntil) {
+			continue
+		}
+		class := series.Occurrence(at)
+		err := models.CreateClass(tx, &class)
+		var conflict *models.ConflictError
+		if errors.As(err, &conflict) {
+			log.Printf("Skipped occurrence %s of class series %d: %s", at.Format(time.RFC3339), series.ID, err)
+			series.ExceptionDates = append(series.ExceptionDates, at)
+			skipped = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		event := events.New(events.ClassCreated, events.SourceClassService, class).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		if err := models.EnqueueEvent(tx, event); err != nil {
+			return err
+		}
+	}
+
+	series.MaterializedUntil = &until
+	if err := models.SaveSeries(tx, series); err != nil {
+		return err
+	}
+	if skipped {
+		return enqueueSeriesEvent(tx, events.ClassSeriesUpdated, *series, correlationID)
+	}
+	return nil
+}
+
+// splitSeries ends a series before at and continues it from at in a new series, which takes over
+// the classes from at on. When at is the first occurrence, the series itself is returned unchanged.
+func splitSeries(tx *gorm.DB, series *models.ClassSeries, at time.Time, correlationID string) (*models.ClassSeries, error) {
+	head, tail, err := splitRule(series, at)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return series, nil
+	}
+
+	following := *series
+	following.ID = 0
+	following.StartsAt = at.UTC()
+	following.LocalStart = ""
+	following.RecurrenceRule = tail.String()
+	following.ExceptionDates = exceptionsBetween(series.ExceptionDates, at, time.Time{})
+	if err := models.CreateSeries(tx, &following); err != nil {
+		return nil, err
+	}
+	if err := models.MoveSeriesClasses(tx, series.ID, following.ID, at); err != nil {
+		return nil, err
+	}
+
+	series.RecurrenceRule = head.String()
+	series.ExceptionDates = exceptionsBetween(series.ExceptionDates, time.Time{}, at)
+	if err := models.SaveSeries(tx, series); err != nil {
+		return nil, err
+	}
+	if err := enqueueSeriesEvent(tx, events.ClassSeriesUpdated, *series, correlationID); err != nil {
+		return nil, err
+	}
+	return &following, nil
+}
+
+// splitRule returns the rule of a series ending before at and the rule continuing it from at. The
+// head is nil when at is the first occurrence.
+func splitRule(series *models.ClassSeries, at time.Time) (*recurrence.Rule, *recurrence.Rule, error) {
+	rule, err := recurrence.Parse(series.RecurrenceRule)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := len(rule.Occurrences(series.Start(), at.Add(-time.Nanosecond), nil))
+	if before == 0 {
+		return nil, rule, nil
+	}
+
+	head, tail := *rule, *rule
+	if rule.Count > 0 {
+		head.Count = before
+		tail.Count = rule.Count - before
+	} else {
+		until := at.Add(-time.Second)
+		head.Until = &until
+	}
+	return &head, &tail, nil
+}
+
+func deleteSeries(tx *gorm.DB, series *models.ClassSeries, correlationID string) error {
+	classes, err := models.GetSeriesClasses(tx, series.ID, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, class := range classes {
+		if err := deleteSeriesClass(tx, class.ID, correlationID); err != nil {
+			return err
+		}
+	}
+	if err := models.DeleteSeries(tx, series.ID); err != nil {
+		return err
+	}
+	event := events.New(events.ClassSeriesDeleted, events.SourceClassService, events.Deleted{ID: series.ID}).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return models.EnqueueEvent(tx, event)
+}
+
+// lockOccurrence locks a series and returns it with one of its classes.
+func lockOccurrence(tx *gorm.DB, seriesID, classID uint) (*models.ClassSeries, *models.Class, error) {
+	series, err := models.LockSeries(tx, seriesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	class, err := models.GetClassByID(tx, classID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if class.SeriesID == nil || *class.SeriesID != seriesID || class.OccurrenceAt == nil {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return series, class, nil
+}
+
+func updateSeriesClass(tx *gorm.DB, id uint, changes *models.Class, correlationID string) error {
+	class, err := ApplyClassUpdate(tx, id, changes, correlationID)
+	if err != nil {
+		return err
+	}
+	event := events.New(events.ClassUpdated, events.SourceClassService, *class).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return models.EnqueueEvent(tx, event)
+}
+
+func deleteSeriesClass(tx *gorm.DB, id uint, correlationID string) error {
+	if err := models.DeleteClass(tx, id); err != nil {
+		return err
+	}
+	event := events.New(events.ClassDeleted, events.SourceClassService, events.Deleted{ID: id}).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return models.EnqueueEvent(tx, event)
+}
+
+func enqueueSeriesEvent(tx *gorm.DB, eventType events.Type, series models.ClassSeries, correlationID string) error {
+	event := events.New(eventType, events.SourceClassService, series).
+		WithCorrelationID(correlationID).
+		MarkPersisted()
+	return models.EnqueueEvent(tx, event)
+}
+
+// occurrenceChanges keeps the fields of a class an occurrence edit may change.
+func occurrenceChanges(class models.Class) models.Class {
+	return models.Class{
+		Title:           class.Title,
+		Description:     class.Description,
+		CourseID:        class.CourseID,
+		InstructorID:    class.InstructorID,
+		ClassTypeID:     class.ClassTypeID,
+		ScheduledAt:     class.ScheduledAt,
+		TimeZone:        class.TimeZone,
+		LocalStart:      class.LocalStart,
+		Duration:        class.Duration,
+		MaxParticipants: class.MaxParticipants,
+		WaitlistEnabled: class.WaitlistEnabled,
+	}
+}
+
+// applyTemplateChanges copies the non-zero fields of changes to the template of a series.
+func applyTemplateChanges(series *models.ClassSeries, changes models.Class) {
+	if changes.Title != "" {
+		series.Title = changes.Title
+	}
+	if changes.Description != "" {
+		series.Description = changes.Description
+	}
+	if changes.CourseID != 0 {
+		series.CourseID = changes.CourseID
+	}
+	if changes.InstructorID != 0 {
+		series.InstructorID = changes.InstructorID
+	}
+	if changes.ClassTypeID != 0 {
+		series.ClassTypeID = changes.ClassTypeID
+	}
+	if changes.Duration != 0 {
+		series.Duration = changes.Duration
+	}
+	if changes.MaxParticipants != 0 {
+		series.MaxParticipants = changes.MaxParticipants
+	}
+	if changes.WaitlistEnabled {
+		series.WaitlistEnabled = true
+	}
+}
+
+// exceptionsBetween returns the exceptions in [from, to), a zero bound leaving that side open.
+func exceptionsBetween(exceptions []time.Time, from, to time.Time) []time.Time {
+	kept := []time.Time{}
+	for _, exception := range exceptions {
+		if !from.IsZero() && exception.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !exception.Before(to) {
+			continue
+		}
+		kept = append(kept, exception)
+	}
+	return kept
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"class/models"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB opens the database named by CLASS_TEST_DSN, migrated, inside a transaction rolled back
+// when the test ends. Tests needing it are skipped when the variable is unset.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("CLASS_TEST_DSN")
+	if dsn == "" {
+		t.Skip("CLASS_TEST_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ClassType{}, &models.ClassSeries{}, &models.Class{}, &models.OutboxEvent{}, &models.Enrollment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+func TestDeleteSeriesKeepsPastOccurrences(t *testing.T) {
+	tx := testDB(t)
+
+	classType := models.ClassType{Name: "Series test", DefaultDuration: 60, DefaultCapacity: 10}
+	if err := tx.Create(&classType).Error; err != nil {
+		t.Fatalf("create class type: %v", err)
+	}
+	start := time.Now().UTC().Add(-7 * 24 * time.Hour).Truncate(time.Second)
+	series := models.ClassSeries{
+		Title:          "Weekly",
+		CompanyID:      1,
+		CourseID:       1,
+		InstructorID:   1,
+		ClassTypeID:    classType.ID,
+		Duration:       60,
+		StartsAt:       start,
+		RecurrenceRule: "FREQ=WEEKLY;COUNT=2",
+	}
+	if err := models.CreateSeries(tx, &series); err != nil {
+		t.Fatalf("create series: %v", err)
+	}
+	past := series.Occurrence(start)
+	past.MaxParticipants = 10
+	if err := tx.Create(&past).Error; err != nil {
+		t.Fatalf("create past occurrence: %v", err)
+	}
+	enrollment := models.Enrollment{ClassID: past.ID, LearnerID: 1, Status: models.EnrollmentEnrolled}
+	if err := tx.Create(&enrollment).Error; err != nil {
+		t.Fatalf("create enrollment: %v", err)
+	}
+
+	service := NewSeriesService(tx, nil, time.Hour)
+	if err := service.DeleteSeries(series.ID, ""); err != nil {
+		t.Fatalf("delete series: %v", err)
+	}
+
+	if _, err := models.GetSeriesByID(tx, series.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("deleted series still found: %v", err)
+	}
+	var kept models.ClassSeries
+	if err := tx.Unscoped().First(&kept, series.ID).Error; err != nil {
+		t.Errorf("series row was removed: %v", err)
+	}
+	var class models.Class
+	if err := tx.Unscoped().First(&class, past.ID).Error; err != nil {
+		t.Fatalf("past occurrence was removed: %v", err)
+	}
+	if !class.DeletedAt.Valid {
+		t.Errorf("past occurrence is not marked deleted")
+	}
+	var count int64
+	if err := tx.Model(&models.Enrollment{}).Where("class_id = ?", past.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count enrollments: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("enrollments of the past occurrence = %d, want 1", count)
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrUnroutable = errors.New("message was returned as unroutable")
+	ErrNacked     = errors.New("message was nacked by the broker")
+)
+
+// publisherConfirms tracks the publishes of one channel in confirm mode until the broker
+// acks or nacks them. Delivery tags are assigned in publish order, so publishes are serialized.
+type publisherConfirms struct {
+	mu       sync.Mutex
+	nextTag  uint64
+	inflight map[uint64]inflightPublish
+	returned map[string]amqp.Return
+}
+
+type inflightPublish struct {
+	messageID string
+	done      chan error
+}
+
+// newPublisherConfirms puts the channel in confirm mode and starts resolving its confirmations.
+func newPublisherConfirms(channel *amqp.Channel) (*publisherConfirms, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, err
+	}
+
+	confirms := &publisherConfirms{
+		inflight: map[uint64]inflightPublish{},
+		returned: map[string]amqp.Return{},
+	}
+	acks := channel.NotifyPublish(make(chan amqp.Confirmation, 128))
+	returns := channel.NotifyReturn(make(chan amqp.Return, 128))
+	go confirms.track(acks, returns)
+
+	return confirms, nil
+}
+
+// publish publishes with mandatory routing and waits until the broker confirms the message.
+func (c *publisherConfirms) publish(ctx context.Context, channel *amqp.Channel, exchange, routingKey string, publishing amqp.Publishing) error {
+	done := make(chan error, 1)
+
+	c.mu.Lock()
+	c.nextTag++
+	tag := c.nextTag
+	if publishing.MessageId == "" {
+		publishing.MessageId = fmt.Sprintf("publish-%d", tag)
+	}
+	c.inflight[tag] = inflightPublish{messageID: publishing.MessageId, done: done}
+	if err := channel.Publish(exchange, routingKey, true, false, publishing); err != nil {
+		delete(c.inflight, tag)
+		c.mu.Unlock()
+		return err
+	}
+	c.mu.Unlock()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *publisherConfirms) track(acks <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case returned, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.recordReturn(returned)
+
+		case confirmation, ok := <-acks:
+			if !ok {
+				c.failAll(ErrDisconnected)
+				return
+			}
+			// The broker sends the return of a message before its ack, so drain the
+			// returns already received before resolving.
+			for drained := false; !drained; {
+				select {
+				case returned, ok := <-returns:
+					if !ok {
+						returns = nil
+						drained = true
+						continue
+					}
+					c.recordReturn(returned)
+				default:
+					drained = true
+				}
+			}
+			c.resolve(confirmation)
+		}
+	}
+}
+
+func (c *publisherConfirms) recordReturn(returned amqp.Return) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.returned[returned.MessageId] = returned
+}
+
+func (c *publisherConfirms) resolve(confirmation amqp.Confirmation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	publish, ok := c.inflight[confirmation.DeliveryTag]
+	if !ok {
+		return
+	}
+	delete(c.inflight, confirmation.DeliveryTag)
+
+	if returned, ok := c.returned[publish.messageID]; ok {
+		delete(c.returned, publish.messageID)
+		publish.done <- fmt.Errorf("%w: %s on %s with routing key %s", ErrUnroutable, returned.ReplyText, returned.Exchange, returned.RoutingKey)
+		return
+	}
+	if !confirmation.Ack {
+		publish.done <- ErrNacked
+		return
+	}
+	publish.done <- nil
+}
+
+func (c *publisherConfirms) failAll(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for tag, publish := range c.inflight {
+		publish.done <- err
+		delete(c.inflight, tag)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"leecho/events"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrUnknownQueue       = errors.New("unknown queue")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
+
+// DeadLetter is a message a consumer gave up on.
+type DeadLetter struct {
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Queue          string          `json:"queue"`
+	Retries        int             `json:"retries"`
+	Error          string          `json:"error"`
+	DeadLetteredAt string          `json:"dead_lettered_at"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// OnDeadLetter registers a callback run whenever a consumer gives up on a message.
+func (r *RabbitMQConfig) OnDeadLetter(callback func(DeadLetter)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onDead = callback
+}
+
+// ListDeadLetters returns up to limit dead letters of a queue without removing them.
+func (r *RabbitMQConfig) ListDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	deadLetters := []DeadLetter{}
+	err := r.scanDeadLetters(queueName, limit, func(_ *amqp.Channel, _ amqp.Delivery, deadLetter DeadLetter) (bool, error) {
+		deadLetters = append(deadLetters, deadLetter)
+		return false, nil
+	})
+	return deadLetters, err
+}
+
+// GetDeadLetter returns the dead letter of a queue carrying eventID, looking at most at limit messages.
+func (r *RabbitMQConfig) GetDeadLetter(queueName, eventID string, limit int) (*DeadLetter, error) {
+	var found *DeadLetter
+	err := r.scanDeadLetters(queueName, limit, func(_ *amqp.Channel, _ amqp.Delivery, deadLetter DeadLetter) (bool, error) {
+		if deadLetter.EventID != eventID {
+			return false, nil
+		}
+		found = &deadLetter
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// ReplayDeadLetters publishes the dead letters of a queue back to it with a fresh retry budget.
+// When eventID is empty, every dead letter within limit is replayed. It returns how many were replayed.
+func (r *RabbitMQConfig) ReplayDeadLetters(queueName, eventID string, limit int) (int, error) {
+	replayed := 0
+	err := r.scanDeadLetters(queueName, limit, func(channel *amqp.Channel, msg amqp.Delivery, deadLetter DeadLetter) (bool, error) {
+		if eventID != "" && deadLetter.EventID != eventID {
+			return false, nil
+		}
+
+		headers := copyHeaders(msg.Headers)
+		delete(headers, events.RetryCountHeader)
+		delete(headers, events.LastErrorHeader)
+		delete(headers, events.DeadLetteredAtHeader)
+
+		err := channel.Publish("", queueName, false, false, amqp.Publishing{
+			Headers:     headers,
+			ContentType: msg.ContentType,
+			MessageId:   msg.MessageId,
+			Body:        msg.Body,
+		})
+		if err != nil {
+			return true, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return true, err
+		}
+		replayed++
+		return eventID != "", nil
+	})
+	return replayed, err
+}
+
+// PurgeDeadLetters drops the dead letters of a queue, or only the one carrying eventID when it is set.
+// It returns how many were dropped.
+func (r *RabbitMQConfig) PurgeDeadLetters(queueName, eventID string, limit int) (int, error) {
+	if !r.hasQueue(queueName) {
+		return 0, ErrUnknownQueue
+	}
+
+	if eventID == "" {
+		channel, err := r.currentChannel()
+		if err != nil {
+			return 0, err
+		}
+		return channel.QueuePurge(events.DeadLetterQueueName(queueName), false)
+	}
+
+	purged := 0
+	err := r.scanDeadLetters(queueName, limit, func(_ *amqp.Channel, msg amqp.Delivery, deadLetter DeadLetter) (bool, error) {
+		if deadLetter.EventID != eventID {
+			return false, nil
+		}
+		if err := msg.Ack(false); err != nil {
+			return true, err
+		}
+		purged++
+		return true, nil
+	})
+	return purged, err
+}
+
+// scanDeadLetters fetches up to limit dead letters of a queue on a dedicated channel and passes
+// each to visit until it asks to stop. Messages visit leaves unacknowledged are returned to the
+// dead-letter queue, in order, when the channel closes.
+func (r *RabbitMQConfig) scanDeadLetters(queueName string, limit int, visit func(*amqp.Channel, amqp.Delivery, DeadLetter) (bool, error)) error {
+	if !r.hasQueue(queueName) {
+		return ErrUnknownQueue
+	}
+
+	connection, err := r.currentConnection()
+	if err != nil {
+		return err
+	}
+	channel, err := connection.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	for i := 0; i < limit; i++ {
+		msg, ok, err := channel.Get(events.DeadLetterQueueName(queueName), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		stop, err := visit(channel, msg, newDeadLetter(queueName, msg))
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RabbitMQConfig) hasQueue(queueName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.topology.Queue(queueName)
+	return ok
+}
+
+func newDeadLetter(queueName string, msg amqp.Delivery) DeadLetter {
+	deadLetter := DeadLetter{
+		Queue:   queueName,
+		Retries: retryCount(msg.Headers),
+		Body:    msg.Body,
+	}
+	if lastError, ok := msg.Headers[events.LastErrorHeader].(string); ok {
+		deadLetter.Error = lastError
+	}
+	if deadLetteredAt, ok := msg.Headers[events.DeadLetteredAtHeader].(string); ok {
+		deadLetter.DeadLetteredAt = deadLetteredAt
+	}
+
+	if event, err := events.Parse(msg.Body); err == nil {
+		deadLetter.EventID = event.ID
+		deadLetter.EventType = string(event.Type)
+	}
+	if !json.Valid(msg.Body) {
+		deadLetter.Body, _ = json.Marshal(string(msg.Body))
+	}
+	return deadLetter
+}
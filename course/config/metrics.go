@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// publishLatencyBuckets are the upper bounds, in seconds, of the publish latency histogram.
+var publishLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PublishMetrics counts event publishes and their confirm latency per event type.
+type PublishMetrics struct {
+	mu     sync.Mutex
+	byType map[string]*publishStats
+}
+
+type publishStats struct {
+	succeeded  uint64
+	failed     uint64
+	latencySum float64
+	buckets    []uint64
+}
+
+func NewPublishMetrics() *PublishMetrics {
+	return &PublishMetrics{byType: map[string]*publishStats{}}
+}
+
+// Observe records one publish of an event type, failed when err is not nil.
+func (m *PublishMetrics) Observe(eventType string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.byType[eventType]
+	if !ok {
+		stats = &publishStats{buckets: make([]uint64, len(publishLatencyBuckets))}
+		m.byType[eventType] = stats
+	}
+
+	if err != nil {
+		stats.failed++
+		return
+	}
+	stats.succeeded++
+	seconds := latency.Seconds()
+	stats.latencySum += seconds
+	for i, bound := range publishLatencyBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (m *PublishMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	eventTypes := make([]string, 0, len(m.byType))
+	for eventType := range m.byType {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+
+	fmt.Fprintln(w, "# HELP rabbitmq_publish_total Events published to RabbitMQ, by event type and result.")
+	fmt.Fprintln(w, "# TYPE rabbitmq_publish_total counter")
+	for _, eventType := range eventTypes {
+		stats := m.byType[eventType]
+		fmt.Fprintf(w, "rabbitmq_publish_total{event_type=%q,result=\"success\"} %d\n", eventType, stats.succeeded)
+		fmt.Fprintf(w, "rabbitmq_publish_total{event_type=%q,result=\"failure\"} %d\n", eventType, stats.failed)
+	}
+
+	fmt.Fprintln(w, "# HELP rabbitmq_publish_duration_seconds Time until the broker confirmed a successful publish.")
+	fmt.Fprintln(w, "# TYPE rabbitmq_publish_duration_seconds histogram")
+	for _, eventType := range eventTypes {
+		stats := m.byType[eventType]
+		for i, bound := range publishLatencyBuckets {
+			fmt.Fprintf(w, "rabbitmq_publish_duration_seconds_bucket{event_type=%q,le=\"%g\"} %d\n", eventType, bound, stats.buckets[i])
+		}
+		fmt.Fprintf(w, "rabbitmq_publish_duration_seconds_bucket{event_type=%q,le=\"+Inf\"} %d\n", eventType, stats.succeeded)
+		fmt.Fprintf(w, "rabbitmq_publish_duration_seconds_sum{event_type=%q} %g\n", eventType, stats.latencySum)
+		fmt.Fprintf(w, "rabbitmq_publish_duration_seconds_count{event_type=%q} %d\n", eventType, stats.succeeded)
+	}
+}
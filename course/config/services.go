@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultClassServiceURL reaches the class service by its service name; both services listen on
+// :3000, so localhost would be this service.
+const defaultClassServiceURL = "http://class:3000"
+
+// ClassServiceURL returns the base URL of the class service, from CLASS_SERVICE_URL.
+func ClassServiceURL() (string, error) {
+	value := os.Getenv("CLASS_SERVICE_URL")
+	if value == "" {
+		return defaultClassServiceURL, nil
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("invalid CLASS_SERVICE_URL %q", value)
+	}
+	return strings.TrimRight(value, "/"), nil
+}
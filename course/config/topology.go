@@ -0,0 +1,41 @@
+package config
+
+import "leecho/events"
+
+// Topology is the RabbitMQ layout of the course service, declared at startup.
+var Topology = events.Topology{
+	Queues: []events.Queue{
+		{
+			Name: "course_events",
+			Bindings: []events.Binding{
+				{Exchange: events.CourseExchange, RoutingKey: "course.*"},
+			},
+		},
+		{
+			Name: "course_search_events",
+			Bindings: []events.Binding{
+				{Exchange: events.CourseExchange, RoutingKey: "course.*"},
+			},
+		},
+		{
+			Name: "course_path_events",
+			Bindings: []events.Binding{
+				{Exchange: events.CourseExchange, RoutingKey: "course_path.*"},
+			},
+		},
+		{
+			Name: "course_class_events",
+			Bindings: []events.Binding{
+				{Exchange: events.ClassExchange, RoutingKey: "class.*"},
+			},
+		},
+	},
+	Emits: []events.Type{
+		events.CourseCreated,
+		events.CourseUpdated,
+		events.CourseDeleted,
+		events.CoursePathCreated,
+		events.CoursePathUpdated,
+		events.CoursePathDeleted,
+	},
+}
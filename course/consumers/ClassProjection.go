@@ -0,0 +1,74 @@
+package consumers
+
+import (
+	"course/config"
+	"course/models"
+	"errors"
+	"fmt"
+	"leecho/events"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// StartClassProjectionConsumer keeps the projection of classes current from the events of the
+// class service.
+func StartClassProjectionConsumer(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	err := rabbitMQConfig.ConsumeEvents("course_class_events", func(event events.Raw) error {
+		// Commands not yet applied by the class service, which publishes the outcome once it is.
+		if !event.Persisted {
+			return nil
+		}
+		return processOnce(db, "course_class_events", event, handleClassFact)
+	})
+	if err != nil {
+		log.Fatalf("Failed to register a consumer for course_class_events: %s", err)
+	}
+
+	log.Println("Waiting for class event messages.")
+}
+
+func handleClassFact(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.ClassCreated, events.ClassUpdated:
+		changed, err := events.DecodePayload[models.Class](event)
+		if err != nil {
+			return 0, err
+		}
+		return projectClass(db, changed.Payload)
+
+	case events.ClassCancelled, events.ClassRescheduled:
+		// Both carry the class with the enrollments the change affected.
+		changed, err := events.DecodePayload[struct {
+			Class models.Class `json:"class"`
+		}](event)
+		if err != nil {
+			return 0, err
+		}
+		return projectClass(db, changed.Payload.Class)
+
+	case events.ClassDeleted:
+		deleted, err := events.DecodePayload[events.Deleted](event)
+		if err != nil {
+			return 0, err
+		}
+		if err := models.DeleteClass(db, deleted.Payload.ID); err != nil {
+			return 0, fmt.Errorf("deleting class from the projection: %w", err)
+		}
+		return deleted.Payload.ID, nil
+
+	default:
+		log.Printf("Unknown event type: %s", event.Type)
+	}
+	return 0, nil
+}
+
+func projectClass(db *gorm.DB, class models.Class) (uint, error) {
+	if class.ID == 0 {
+		return 0, errors.New("no class ID provided in class event")
+	}
+	if err := models.SaveClass(db, &class); err != nil {
+		return 0, fmt.Errorf("saving class in the projection: %w", err)
+	}
+	return class.ID, nil
+}
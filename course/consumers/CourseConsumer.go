@@ -3,153 +3,191 @@ package consumers
 import (
 	"course/config"
 	"course/models"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"leecho/events"
 	"log"
 
 	"gorm.io/gorm"
 )
 
-type CourseEvent struct {
-	EventType string        `json:"event_type"`
-	Course    models.Course `json:"course"`
-	ID        uint          `json:"id"`
-}
-
-type CoursePathEvent struct {
-	EventType  string            `json:"event_type"`
-	CoursePath models.CoursePath `json:"coursePath"`
-	ID         uint              `json:"id"`
-}
-
 func StartCourseEventConsumer(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
 	// Consumer for course_events
 	consumeCourseEvents(rabbitMQConfig, db)
-	// Consumer for coursePath_events
+	// Consumer for course_path_events
 	consumeCoursePathEvents(rabbitMQConfig, db)
+	// Consumer for course_search_events
+	consumeCourseSearchEvents(rabbitMQConfig, db)
 
 	log.Println("Waiting for course and course path event messages.")
 }
 
 func consumeCourseEvents(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
-	msgs, err := rabbitMQConfig.Channel.Consume(
-		"course_events",
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err := rabbitMQConfig.ConsumeEvents("course_events", func(event events.Raw) error {
+		// Already applied by the API in synchronous write mode.
+		if event.Persisted {
+			return nil
+		}
+		return processOnce(db, "course_events", event, handleCourseEvent)
+	})
 	if err != nil {
 		log.Fatalf("Failed to register a consumer for course_events: %s", err)
 	}
-
-	go func() {
-		for msg := range msgs {
-			log.Printf("Received a message from course_events: %s", msg.Body)
-
-			var courseEvent CourseEvent
-			err := json.Unmarshal(msg.Body, &courseEvent)
-			if err != nil {
-				log.Printf("Failed to unmarshal course event data: %s", err)
-				continue
-			}
-
-			switch courseEvent.EventType {
-			case "course.created":
-				log.Printf("Handling course created event for course: %s", courseEvent.Course.Title)
-				if err := models.CreateCourse(db, &courseEvent.Course); err != nil {
-					log.Printf("Failed to insert course into the database: %s", err)
-					continue
-				}
-				log.Printf("Course '%s' inserted into the database successfully!", courseEvent.Course.Title)
-
-			case "course.updated":
-				log.Printf("Handling course updated event for course: %s", courseEvent.Course.Title)
-				if courseEvent.Course.ID == 0 {
-					log.Printf("No Course ID provided for update event")
-					continue
-				}
-
-				if err := models.UpdateCourse(db, courseEvent.Course.ID, &courseEvent.Course); err != nil {
-					log.Printf("Failed to update course in the database: %s", err)
-					continue
-				}
-				log.Printf("Course '%s' updated in the database successfully!", courseEvent.Course.Title)
-
-			case "course.deleted":
-				log.Printf("Handling course deleted event for course ID: %d", courseEvent.ID)
-				if err := models.DeleteCourse(db, courseEvent.ID); err != nil {
-					log.Printf("Failed to delete course from the database: %s", err)
-					continue
-				}
-				log.Printf("Course with ID %d deleted from the database successfully!", courseEvent.ID)
-
-			default:
-				log.Printf("Unknown event type: %s", courseEvent.EventType)
-			}
-		}
-	}()
 }
 
 func consumeCoursePathEvents(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
-	msgs, err := rabbitMQConfig.Channel.Consume(
-		"coursePath_events",
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err := rabbitMQConfig.ConsumeEvents("course_path_events", func(event events.Raw) error {
+		// Already applied by the API in synchronous write mode.
+		if event.Persisted {
+			return nil
+		}
+		return processOnce(db, "course_path_events", event, handleCoursePathEvent)
+	})
 	if err != nil {
-		log.Fatalf("Failed to register a consumer for coursePath_events: %s", err)
+		log.Fatalf("Failed to register a consumer for course_path_events: %s", err)
 	}
+}
 
-	go func() {
-		for msg := range msgs {
-			log.Printf("Received a message from coursePath_events: %s", msg.Body)
-
-			var coursePathEvent CoursePathEvent
-			err := json.Unmarshal(msg.Body, &coursePathEvent)
-			if err != nil {
-				log.Printf("Failed to unmarshal course path event data: %s", err)
-				continue
-			}
-
-			switch coursePathEvent.EventType {
-			case "coursePath.created":
-				log.Printf("Handling course path created event for course path: %s", coursePathEvent.CoursePath.Title)
-				if err := models.CreateCoursePath(db, &coursePathEvent.CoursePath); err != nil {
-					log.Printf("Failed to insert course path into the database: %s", err)
-					continue
-				}
-				log.Printf("Course Path '%s' inserted into the database successfully!", coursePathEvent.CoursePath.Title)
-
-			case "coursePath.updated":
-				log.Printf("Handling course path updated event for course path: %s", coursePathEvent.CoursePath.Title)
-				if coursePathEvent.CoursePath.ID == 0 {
-					log.Printf("No Course Path ID provided for update event")
-					continue
-				}
-
-				if err := models.UpdateCoursePath(db, coursePathEvent.CoursePath.ID, &coursePathEvent.CoursePath); err != nil {
-					log.Printf("Failed to update course path in the database: %s", err)
-					continue
-				}
-				log.Printf("Course Path '%s' updated in the database successfully!", coursePathEvent.CoursePath.Title)
-
-			case "coursePath.deleted":
-				log.Printf("Handling course path deleted event for course path ID: %d", coursePathEvent.ID)
-				if err := models.DeleteCoursePath(db, coursePathEvent.ID); err != nil {
-					log.Printf("Failed to delete course path from the database: %s", err)
-					continue
-				}
-				log.Printf("Course Path with ID %d deleted from the database successfully!", coursePathEvent.ID)
-
-			default:
-				log.Printf("Unknown event type: %s", coursePathEvent.EventType)
-			}
-		}
-	}()
+// consumeCourseSearchEvents indexes courses for search again once they are created or updated.
+func consumeCourseSearchEvents(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	err := rabbitMQConfig.ConsumeEvents("course_search_events", func(event events.Raw) error {
+		// Commands are indexed once applied, from the event then published.
+		if !event.Persisted {
+			return nil
+		}
+		return processOnce(db, "course_search_events", event, handleCourseSearchEvent)
+	})
+	if err != nil {
+		log.Fatalf("Failed to register a consumer for course_search_events: %s", err)
+	}
+}
+
+func handleCourseEvent(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.CourseCreated:
+		created, err := events.DecodePayload[models.Course](event)
+		if err != nil {
+			return 0, err
+		}
+		course := created.Payload
+		log.Printf("Handling course created event for course: %s", course.Title)
+		if err := models.CreateCourse(db, &course); err != nil {
+			return 0, fmt.Errorf("inserting course into the database: %w", err)
+		}
+		log.Printf("Course '%s' inserted into the database successfully!", course.Title)
+		return course.ID, enqueueCourseFact(db, event, course.ID)
+
+	case events.CourseUpdated:
+		updated, err := events.DecodePayload[models.Course](event)
+		if err != nil {
+			return 0, err
+		}
+		course := updated.Payload
+		log.Printf("Handling course updated event for course: %s", course.Title)
+		if course.ID == 0 {
+			return 0, errors.New("no course ID provided for update event")
+		}
+		if err := models.UpdateCourse(db, course.ID, &course); err != nil {
+			return 0, fmt.Errorf("updating course in the database: %w", err)
+		}
+		log.Printf("Course '%s' updated in the database successfully!", course.Title)
+		return course.ID, enqueueCourseFact(db, event, course.ID)
+
+	case events.CourseDeleted:
+		deleted, err := events.DecodePayload[events.Deleted](event)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Handling course deleted event for course ID: %d", deleted.Payload.ID)
+		if err := models.DeleteCourse(db, deleted.Payload.ID); err != nil {
+			return 0, fmt.Errorf("deleting course from the database: %w", err)
+		}
+		log.Printf("Course with ID %d deleted from the database successfully!", deleted.Payload.ID)
+		fact := events.New(events.CourseDeleted, events.SourceCourseService, deleted.Payload).
+			WithCorrelationID(event.CorrelationID).
+			MarkPersisted()
+		return deleted.Payload.ID, models.EnqueueEvent(db, fact)
+
+	default:
+		log.Printf("Unknown event type: %s", event.Type)
+	}
+	return 0, nil
+}
+
+func handleCoursePathEvent(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.CoursePathCreated:
+		created, err := events.DecodePayload[models.CoursePath](event)
+		if err != nil {
+			return 0, err
+		}
+		coursePath := created.Payload
+		log.Printf("Handling course path created event for course path: %s", coursePath.Title)
+		if err := models.CreateCoursePath(db, &coursePath); err != nil {
+			return 0, fmt.Errorf("inserting course path into the database: %w", err)
+		}
+		log.Printf("Course Path '%s' inserted into the database successfully!", coursePath.Title)
+		return coursePath.ID, nil
+
+	case events.CoursePathUpdated:
+		updated, err := events.DecodePayload[models.CoursePath](event)
+		if err != nil {
+			return 0, err
+		}
+		coursePath := updated.Payload
+		log.Printf("Handling course path updated event for course path: %s", coursePath.Title)
+		if coursePath.ID == 0 {
+			return 0, errors.New("no course path ID provided for update event")
+		}
+		if err := models.UpdateCoursePath(db, coursePath.ID, &coursePath); err != nil {
+			return 0, fmt.Errorf("updating course path in the database: %w", err)
+		}
+		log.Printf("Course Path '%s' updated in the database successfully!", coursePath.Title)
+		return coursePath.ID, nil
+
+	case events.CoursePathDeleted:
+		deleted, err := events.DecodePayload[events.Deleted](event)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Handling course path deleted event for course path ID: %d", deleted.Payload.ID)
+		if err := models.DeleteCoursePath(db, deleted.Payload.ID); err != nil {
+			return 0, fmt.Errorf("deleting course path from the database: %w", err)
+		}
+		log.Printf("Course Path with ID %d deleted from the database successfully!", deleted.Payload.ID)
+		return deleted.Payload.ID, nil
+
+	default:
+		log.Printf("Unknown event type: %s", event.Type)
+	}
+	return 0, nil
+}
+
+// enqueueCourseFact records the course a command created or updated in the outbox, as the
+// persisted event other services follow courses by.
+func enqueueCourseFact(db *gorm.DB, command events.Raw, courseID uint) error {
+	course, err := models.GetCourseByID(db, courseID)
+	if err != nil {
+		return err
+	}
+	fact := events.New(command.Type, events.SourceCourseService, *course).
+		WithCorrelationID(command.CorrelationID).
+		MarkPersisted()
+	return models.EnqueueEvent(db, fact)
+}
+
+func handleCourseSearchEvent(db *gorm.DB, event events.Raw) (uint, error) {
+	switch event.Type {
+	case events.CourseCreated, events.CourseUpdated:
+		changed, err := events.DecodePayload[models.Course](event)
+		if err != nil {
+			return 0, err
+		}
+		if err := models.RefreshCourseSearch(db, changed.Payload.ID); err != nil {
+			return 0, fmt.Errorf("indexing course for search: %w", err)
+		}
+		return changed.Payload.ID, nil
+	}
+	// Deleted courses leave the index with their rows.
+	return 0, nil
 }
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"course/services"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CourseClassController struct {
+	classProjectionService *services.ClassProjectionService
+}
+
+func NewCourseClassController(classProjectionService *services.ClassProjectionService) *CourseClassController {
+	return &CourseClassController{
+		classProjectionService: classProjectionService,
+	}
+}
+
+// ListCourseClasses handles listing the upcoming sessions of a course.
+// @Summary List the upcoming classes of a course
+// @Description Retrieve the scheduled classes of a course that are not over yet, soonest first, as replicated from the class service
+// @Produce json
+// @Param id path uint true "Course ID"
+// @Param limit query int false "Maximum number of classes (default 20, max 100)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Courses
+// @Router /course/{id}/classes [get]
+func (c *CourseClassController) ListCourseClasses(ctx *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid course ID"})
+	}
+
+	classes, err := c.classProjectionService.ListUpcomingClasses(uint(courseID), ctx.QueryInt("limit"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch classes"})
+	}
+
+	return ctx.JSON(fiber.Map{"data": classes})
+}
+
+// RebuildClassProjection handles replaying the classes of the class service into this service.
+// @Summary Rebuild the class projection
+// @Description Replace the classes replicated from class events with the current list of the class service, to recover from missed events
+// @Produce json
+// @Success 200 {object} object
+// @Failure 502 {object} object
+// @Tags Admin
+// @Router /admin/projections/classes/rebuild [post]
+func (c *CourseClassController) RebuildClassProjection(ctx *fiber.Ctx) error {
+	projected, err := c.classProjectionService.Rebuild()
+	if err != nil {
+		return ctx.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Could not rebuild the class projection"})
+	}
+
+	return ctx.JSON(fiber.Map{"projected": projected})
+}
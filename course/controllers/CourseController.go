@@ -5,51 +5,175 @@ import (
 	"course/models"
 	"course/requests"
 	"course/services"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"leecho/events"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type CourseController struct {
 	courseService  *services.CourseService
 	rabbitMQConfig *config.RabbitMQConfig
+	writeMode      config.WriteMode
 }
 
-func NewCourseController(CourseService *services.CourseService, rabbitMQConfig *config.RabbitMQConfig) *CourseController {
+func NewCourseController(CourseService *services.CourseService, rabbitMQConfig *config.RabbitMQConfig, writeMode config.WriteMode) *CourseController {
 	return &CourseController{
 		courseService:  CourseService,
 		rabbitMQConfig: rabbitMQConfig,
+		writeMode:      writeMode,
 	}
 }
 
-// TODO Pagination
-// ListAllCourses handles listing all courses.
-// @Summary List all courses
-// @Description Retrieve a list of all courses
+// ListAllCourses handles listing courses.
+// @Summary List courses
+// @Description Retrieve a page of top-level courses, or of the sub-courses of parent_course_id, with their instructors and sub-courses. Pages are read by cursor, or from offset when given. The response carries the total count and links to the next and previous pages.
 // @Produce json
-// @Success 200 {array} models.Course
+// @Param category query string false "Category"
+// @Param tag query string false "Tag name or slug"
+// @Param instructor_id query uint false "Instructor ID"
+// @Param parent_course_id query uint false "Parent course ID"
+// @Param min_enrollment_limit query int false "Lowest enrollment limit, inclusive"
+// @Param max_enrollment_limit query int false "Highest enrollment limit, inclusive"
+// @Param sort query string false "Sort by title, created_at or category (default created_at)"
+// @Param order query string false "asc or desc (default asc)"
+// @Param cursor query string false "Cursor returned with the previous page"
+// @Param offset query int false "Rows to skip, instead of a cursor"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
 // @Failure 500 {object} object
 // @Router /courses [get]
 // @tags Courses
 func (c *CourseController) ListAllCourses(ctx *fiber.Ctx) error {
-	courses, err := c.courseService.ListAllCourses()
+	filter, err := parseCourseFilter(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	listing, err := c.courseService.ListCourses(filter, options)
+	if err != nil {
+		return listingError(ctx, err, "Failed to list courses")
+	}
+
+	return respondWithListing(ctx, listing)
+}
+
+// SearchCourses handles searching courses.
+// @Summary Search courses
+// @Description Full-text search of course titles, descriptions, categories, tags and instructors, most relevant first. Misspelt words are corrected, and courses matching the corrected query match too. Each hit carries its title and description with the matched words in <mark> tags. Facets count all matching courses by category and by tag.
+// @Produce json
+// @Param q query string true "Search query; supports quoted phrases, or and -word"
+// @Param category query string false "Category"
+// @Param tag query string false "Tag name or slug"
+// @Param offset query int false "Hits to skip"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} services.SearchResult
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Router /courses/search [get]
+// @tags Courses
+func (c *CourseController) SearchCourses(ctx *fiber.Ctx) error {
+	search := models.CourseSearch{
+		Query:    ctx.Query("q"),
+		Category: ctx.Query("category"),
+		Tag:      ctx.Query("tag"),
+	}
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if options.Cursor != "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Search is paged by offset"})
+	}
+	offset := 0
+	if options.Offset != nil {
+		offset = *options.Offset
+	}
+
+	result, err := c.courseService.SearchCourses(search, offset, options.Limit)
+	if errors.Is(err, services.ErrEmptySearch) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Query is required"})
+	}
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list courses"})
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search courses"})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": courses})
+	links := fiber.Map{"next": nil, "prev": nil}
+	if result.HasNext {
+		links["next"] = pageLink(ctx, &result.Listing, "", 1)
+	}
+	if result.HasPrev {
+		links["prev"] = pageLink(ctx, &result.Listing, "", -1)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"query":           result.Query,
+		"corrected_query": result.CorrectedQuery,
+		"data":            result.Data,
+		"total":           result.Total,
+		"limit":           result.Limit,
+		"offset":          result.Offset,
+		"facets":          result.Facets,
+		"links":           links,
+	})
+}
+
+func parseCourseFilter(ctx *fiber.Ctx) (models.CourseFilter, error) {
+	filter := models.CourseFilter{
+		Category: ctx.Query("category"),
+		Tag:      ctx.Query("tag"),
+	}
+	if value := ctx.Query("instructor_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filter, errors.New("Invalid instructor_id")
+		}
+		filter.InstructorID = uint(id)
+	}
+	if value := ctx.Query("parent_course_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filter, errors.New("Invalid parent_course_id")
+		}
+		parentCourseID := uint(id)
+		filter.ParentCourseID = &parentCourseID
+	}
+
+	bounds := map[string]**int{
+		"min_enrollment_limit": &filter.MinEnrollmentLimit,
+		"max_enrollment_limit": &filter.MaxEnrollmentLimit,
+	}
+	for key, target := range bounds {
+		value := ctx.Query(key)
+		if value == "" {
+			continue
+		}
+		bound, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s", key)
+		}
+		*target = &bound
+	}
+	return filter, nil
 }
 
 // CreateCourse handles the creation of a course.
 // @Summary Create a course
-// @Description Create a new course
+// @Description Create a new course. In synchronous write mode the persisted course is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param course body requests.CourseCreateRequest true "CourseCreateRequest"
-// @Success 201 {object} requests.CourseCreateRequest
+// @Success 201 {object} models.Course
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 500 {object} object
 // @Router /course [post]
 // @tags Courses
 func (c *CourseController) CreateCourse(ctx *fiber.Ctx) error {
@@ -63,33 +187,39 @@ func (c *CourseController) CreateCourse(ctx *fiber.Ctx) error {
 		Description: courseRequest.Description,
 		Category:    courseRequest.Category,
 	}
-
-	courseEvent := map[string]interface{}{
-		"event_type":   "course.created",
-		"service_name": "course_service",
-		"course":       course,
+	for _, name := range courseRequest.Tags {
+		// Checked here, as commands with invalid tags would fail on every retry.
+		if models.TagSlug(name) == "" {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tag names need a letter or digit"})
+		}
+		course.Tags = append(course.Tags, models.Tag{Name: name})
 	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course"})
+	if c.writeMode == config.WriteModeSync {
+		if err := c.courseService.CreateCourse(&course, correlationID); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create course"})
+		}
+		return ctx.Status(fiber.StatusCreated).JSON(course)
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_events", courseJSON); err != nil {
+	operation, err := c.courseService.RequestCourseCreation(course, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create course"})
 	}
-
-	return ctx.Status(fiber.StatusCreated).JSON(course)
+	return acceptOperation(ctx, operation)
 }
 
 // DeleteCourse deletes a course.
 // @Summary Delete a course
-// @Description Delete a course by ID
+// @Description Delete a course by ID. In asynchronous write mode an operation to poll is returned.
 // @Accept json
 // @Produce json
 // @Param id body uint true "Course ID"
 // @Success 200 {object} object
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Router /course [delete]
 // @tags Courses
@@ -102,33 +232,36 @@ func (c *CourseController) DeleteCourse(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	courseEvent := map[string]interface{}{
-		"event_type":   "course.deleted",
-		"service_name": "course_service",
-		"id":           requestBody.ID,
-		"timestamp":    time.Now().Unix(),
-	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course ID"})
+	if c.writeMode == config.WriteModeSync {
+		err := c.courseService.DeleteCourse(requestBody.ID, correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete course"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course deleted successfully", "id": requestBody.ID})
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_events", courseJSON); err != nil {
+	operation, err := c.courseService.RequestCourseDeletion(requestBody.ID, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete course"})
 	}
-
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course deleted successfully", "id": requestBody.ID})
+	return acceptOperation(ctx, operation)
 }
 
 // UpdateCourse updates a course.
 // @Summary Update a course
-// @Description Update an existing course by ID
+// @Description Update an existing course by ID. In synchronous write mode the persisted course is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param course body models.Course true "Course"
 // @Success 200 {object} models.Course
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Router /course [put]
 // @tags Courses
@@ -143,32 +276,34 @@ func (c *CourseController) UpdateCourse(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Course ID is required for updating"})
 	}
 
-	courseEvent := map[string]interface{}{
-		"event_type":   "course.updated",
-		"service_name": "course_service",
-		"course":       course,
-		"timestamp":    time.Now().Unix(),
-	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course data"})
+	if c.writeMode == config.WriteModeSync {
+		err := c.courseService.UpdateCourse(course.ID, &course, correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update course"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course updated successfully", "course": course})
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_events", courseJSON); err != nil {
+	operation, err := c.courseService.RequestCourseUpdate(course, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update course"})
 	}
-
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course updated successfully", "course": course})
+	return acceptOperation(ctx, operation)
 }
 
 // DeleteAllCourses deletes multiple courses.
 // @Summary Delete multiple courses
-// @Description Delete multiple courses by their IDs
+// @Description Delete multiple courses by their IDs. In asynchronous write mode one operation per course is returned.
 // @Accept json
 // @Produce json
 // @Param ids body []uint true "Course IDs"
 // @Success 200 {object} object
+// @Success 202 {object} object
 // @Failure 400 {object} object
 // @Failure 500 {object} object
 // @Router /courses/delete [delete]
@@ -182,25 +317,20 @@ func (c *CourseController) DeleteAllCourses(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	for _, id := range requestBody.IDs {
-		courseEvent := map[string]interface{}{
-			"event_type":   "course.deleted",
-			"service_name": "course_service",
-			"id":           id,
-			"timestamp":    time.Now().Unix(),
-		}
-
-		courseJSON, err := json.Marshal(courseEvent)
-		if err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course ID"})
-		}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-		if err := c.rabbitMQConfig.PublishMessage("course_events", courseJSON); err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete course"})
+	if c.writeMode == config.WriteModeSync {
+		if err := c.courseService.DeleteMultipleCourses(requestBody.IDs, correlationID); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete courses"})
 		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Courses deleted successfully", "ids": requestBody.IDs})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Courses deleted successfully", "ids": requestBody.IDs})
+	operations, err := c.courseService.RequestMultipleCourseDeletion(requestBody.IDs, correlationID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete courses"})
+	}
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{"data": operations})
 }
 
 // GetCourseWithSubcourses gets a course with its subcourses.
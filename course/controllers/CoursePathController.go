@@ -4,50 +4,75 @@ import (
 	"course/config"
 	"course/models"
 	"course/services"
-	"encoding/json"
+	"errors"
+	"leecho/events"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type CoursePathController struct {
 	coursePathService *services.CoursePathService
 	rabbitMQConfig    *config.RabbitMQConfig
+	writeMode         config.WriteMode
 }
 
-func NewCoursePathController(CoursePathService *services.CoursePathService, rabbitMQConfig *config.RabbitMQConfig) *CoursePathController {
+func NewCoursePathController(CoursePathService *services.CoursePathService, rabbitMQConfig *config.RabbitMQConfig, writeMode config.WriteMode) *CoursePathController {
 	return &CoursePathController{
 		coursePathService: CoursePathService,
 		rabbitMQConfig:    rabbitMQConfig,
+		writeMode:         writeMode,
 	}
 }
 
-// ListAllCoursePaths handles listing all course paths.
-// @Summary List all course paths
-// @Description Retrieve a list of all course paths
+// ListAllCoursePaths handles listing course paths.
+// @Summary List course paths
+// @Description Retrieve a page of course paths with their courses, optionally only those including course_id. Pages are read by cursor, or from offset when given. The response carries the total count and links to the next and previous pages.
 // @Produce json
-// @Success 200 {array} models.CoursePath
+// @Param course_id query uint false "Course ID"
+// @Param sort query string false "Sort by title or created_at (default created_at)"
+// @Param order query string false "asc or desc (default asc)"
+// @Param cursor query string false "Cursor returned with the previous page"
+// @Param offset query int false "Rows to skip, instead of a cursor"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
 // @Failure 500 {object} object
 // @Router /coursepaths [get]
 // @tags CoursePaths
 func (c *CoursePathController) ListAllCoursePaths(ctx *fiber.Ctx) error {
-	coursePaths, err := c.coursePathService.ListAllCoursePaths()
+	var filter models.CoursePathFilter
+	if value := ctx.Query("course_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid course_id"})
+		}
+		filter.CourseID = uint(id)
+	}
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	listing, err := c.coursePathService.ListCoursePaths(filter, options)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list course paths"})
+		return listingError(ctx, err, "Failed to list course paths")
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": coursePaths})
+	return respondWithListing(ctx, listing)
 }
 
 // CreateCoursePath handles the creation of a course path.
 // @Summary Create a course path
-// @Description Create a new course path
+// @Description Create a new course path. In synchronous write mode the persisted course path is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param coursePath body models.CoursePath true "CoursePath"
 // @Success 201 {object} models.CoursePath
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 500 {object} object
 // @Router /coursepath [post]
 // @tags CoursePaths
 func (c *CoursePathController) CreateCoursePath(ctx *fiber.Ctx) error {
@@ -57,32 +82,32 @@ func (c *CoursePathController) CreateCoursePath(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	courseEvent := map[string]interface{}{
-		"event_type":   "course_path.created",
-		"service_name": "course_path_service",
-		"course_path":  coursePath,
-	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course path"})
+	if c.writeMode == config.WriteModeSync {
+		if err := c.coursePathService.CreateCoursePath(&coursePath, correlationID); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create course path"})
+		}
+		return ctx.Status(fiber.StatusCreated).JSON(coursePath)
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_path_events", courseJSON); err != nil {
+	operation, err := c.coursePathService.RequestCoursePathCreation(coursePath, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create course path"})
 	}
-
-	return ctx.Status(fiber.StatusCreated).JSON(coursePath)
+	return acceptOperation(ctx, operation)
 }
 
 // UpdateCoursePath updates a course path.
 // @Summary Update a course path
-// @Description Update an existing course path by ID
+// @Description Update an existing course path by ID. In synchronous write mode the persisted course path is returned, otherwise an operation to poll.
 // @Accept json
 // @Produce json
 // @Param coursePath body models.CoursePath true "CoursePath"
 // @Success 200 {object} models.CoursePath
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Router /coursepath [put]
 // @tags CoursePaths
@@ -97,33 +122,36 @@ func (c *CoursePathController) UpdateCoursePath(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Course Path ID is required for updating"})
 	}
 
-	courseEvent := map[string]interface{}{
-		"event_type":   "course_path.updated",
-		"service_name": "course_path_service",
-		"course_path":  coursePath,
-		"timestamp":    time.Now().Unix(),
-	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course path data"})
+	if c.writeMode == config.WriteModeSync {
+		err := c.coursePathService.UpdateCoursePath(coursePath.ID, &coursePath, correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course path not found"})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update course path"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course path updated successfully", "course_path": coursePath})
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_path_events", courseJSON); err != nil {
+	operation, err := c.coursePathService.RequestCoursePathUpdate(coursePath, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update course path"})
 	}
-
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course path updated successfully", "course_path": coursePath})
+	return acceptOperation(ctx, operation)
 }
 
 // DeleteCoursePath deletes a course path.
 // @Summary Delete a course path
-// @Description Delete a course path by ID
+// @Description Delete a course path by ID. In asynchronous write mode an operation to poll is returned.
 // @Accept json
 // @Produce json
 // @Param id body uint true "Course Path ID"
 // @Success 200 {object} object
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} object
+// @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Router /coursepath [delete]
 // @tags CoursePaths
@@ -136,23 +164,24 @@ func (c *CoursePathController) DeleteCoursePath(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	courseEvent := map[string]interface{}{
-		"event_type":   "course_path.deleted",
-		"service_name": "course_path_service",
-		"id":           requestBody.ID,
-		"timestamp":    time.Now().Unix(),
-	}
+	correlationID := ctx.Get(events.CorrelationHeader)
 
-	courseJSON, err := json.Marshal(courseEvent)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not serialize course path ID"})
+	if c.writeMode == config.WriteModeSync {
+		err := c.coursePathService.DeleteCoursePath(requestBody.ID, correlationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course path not found"})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete course path"})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course path deleted successfully", "id": requestBody.ID})
 	}
 
-	if err := c.rabbitMQConfig.PublishMessage("course_path_events", courseJSON); err != nil {
+	operation, err := c.coursePathService.RequestCoursePathDeletion(requestBody.ID, correlationID)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete course path"})
 	}
-
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Course path deleted successfully", "id": requestBody.ID})
+	return acceptOperation(ctx, operation)
 }
 
 // GetCoursePathByID retrieves a course path by ID.
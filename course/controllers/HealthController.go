@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"course/config"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type HealthController struct {
+	rabbitMQConfig *config.RabbitMQConfig
+	db             *gorm.DB
+}
+
+func NewHealthController(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) *HealthController {
+	return &HealthController{
+		rabbitMQConfig: rabbitMQConfig,
+		db:             db,
+	}
+}
+
+// Health reports the state of the service dependencies.
+// @Summary Service health
+// @Description Report the RabbitMQ connection state and database reachability
+// @Produce json
+// @Success 200 {object} object
+// @Failure 503 {object} object
+// @Tags Health
+// @Router /health [get]
+func (c *HealthController) Health(ctx *fiber.Ctx) error {
+	rabbitMQ := c.rabbitMQConfig.Status()
+
+	database := "up"
+	if sqlDB, err := c.db.DB(); err != nil || sqlDB.PingContext(ctx.Context()) != nil {
+		database = "down"
+	}
+
+	status := fiber.StatusOK
+	health := "ok"
+	if rabbitMQ.State != config.StateConnected || database != "up" {
+		status = fiber.StatusServiceUnavailable
+		health = "degraded"
+	}
+
+	return ctx.Status(status).JSON(fiber.Map{"status": health, "rabbitmq": rabbitMQ, "database": database})
+}
+
+// Metrics exposes the publish metrics in the Prometheus text format.
+// @Summary Service metrics
+// @Description Report per event type publish counts, failures and confirm latency
+// @Produce plain
+// @Success 200 {string} string
+// @Tags Health
+// @Router /metrics [get]
+func (c *HealthController) Metrics(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+	c.rabbitMQConfig.Metrics().WritePrometheus(ctx)
+	return nil
+}
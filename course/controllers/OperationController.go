@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"bufio"
+	"course/models"
+	"course/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// operationStreamTimeout bounds an operation stream; clients reconnect to keep following it.
+const operationStreamTimeout = 5 * time.Minute
+
+type OperationController struct {
+	courseService *services.CourseService
+}
+
+func NewOperationController(courseService *services.CourseService) *OperationController {
+	return &OperationController{
+		courseService: courseService,
+	}
+}
+
+// GetOperation handles fetching the status of an asynchronous command.
+// @Summary Get an operation
+// @Description Retrieve the status of a command accepted in asynchronous write mode. With wait, the request is held until the operation is done or the wait elapses.
+// @Produce json
+// @Param id path string true "Operation ID"
+// @Param wait query int false "Seconds to wait for the operation to finish (max 60)"
+// @Success 200 {object} models.Operation
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Operations
+// @Router /operations/{id} [get]
+func (c *OperationController) GetOperation(ctx *fiber.Ctx) error {
+	wait := time.Duration(ctx.QueryInt("wait")) * time.Second
+	if wait > services.MaxOperationWait {
+		wait = services.MaxOperationWait
+	}
+
+	operation, err := c.courseService.WaitForOperation(ctx.Params("id"), wait)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Operation not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch operation"})
+	}
+
+	return ctx.JSON(operation)
+}
+
+// StreamOperation streams the status of an asynchronous command as server-sent events.
+// @Summary Stream an operation
+// @Description Stream every status change of a command accepted in asynchronous write mode, until it is done
+// @Produce text/event-stream
+// @Param id path string true "Operation ID"
+// @Success 200 {object} models.Operation
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Tags Operations
+// @Router /operations/{id}/stream [get]
+func (c *OperationController) StreamOperation(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	operation, err := c.courseService.GetOperation(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Operation not found"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch operation"})
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		deadline := time.Now().Add(operationStreamTimeout)
+		for {
+			if err := writeOperationEvent(w, operation); err != nil {
+				return
+			}
+			if operation.Done() || time.Now().After(deadline) {
+				return
+			}
+
+			last := *operation
+			for operation.Status == last.Status && operation.Error == last.Error && time.Now().Before(deadline) {
+				time.Sleep(services.OperationPollInterval)
+				// The comment keeps the connection alive, and its flush fails once the client is gone.
+				if err := writeKeepAlive(w); err != nil {
+					return
+				}
+				next, err := c.courseService.GetOperation(id)
+				if err != nil {
+					log.Printf("Failed to read operation %s: %s", id, err)
+					return
+				}
+				operation = next
+			}
+		}
+	})
+	return nil
+}
+
+// acceptOperation answers 202 with the operation tracking an asynchronous command.
+func acceptOperation(ctx *fiber.Ctx, operation *models.Operation) error {
+	ctx.Location("/operations/" + operation.ID)
+	return ctx.Status(fiber.StatusAccepted).JSON(operation)
+}
+
+// writeOperationEvent writes one server-sent event carrying the operation and flushes it, which
+// fails once the client is gone.
+func writeOperationEvent(w *bufio.Writer, operation *models.Operation) error {
+	data, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", operation.Status, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeKeepAlive writes a server-sent event comment and flushes it.
+func writeKeepAlive(w *bufio.Writer) error {
+	if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
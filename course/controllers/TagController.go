@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"course/models"
+	"course/services"
+	"errors"
+	"leecho/events"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type TagController struct {
+	tagService *services.TagService
+}
+
+func NewTagController(tagService *services.TagService) *TagController {
+	return &TagController{
+		tagService: tagService,
+	}
+}
+
+// ListTags handles listing tags with their usage counts.
+// @Summary List tags
+// @Description Retrieve the tags with the number of courses each labels, by name or, for a tag cloud, most used first
+// @Produce json
+// @Param sort query string false "name or usage (default name)"
+// @Param limit query int false "Maximum number of tags (default all)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 500 {object} object
+// @Router /tags [get]
+// @tags Tags
+func (c *TagController) ListTags(ctx *fiber.Ctx) error {
+	sort := ctx.Query("sort", "name")
+	if sort != "name" && sort != "usage" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Sort must be name or usage"})
+	}
+	limit := 0
+	if value := ctx.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+		}
+	}
+
+	tags, err := c.tagService.ListTags(sort == "usage", limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to list tags"})
+	}
+
+	return ctx.JSON(fiber.Map{"data": tags})
+}
+
+// CreateTag handles the creation of a tag.
+// @Summary Create a tag
+// @Description Create a tag. Its slug is its name lowercased, with runs of anything but letters and digits turned into dashes, and must be unique.
+// @Accept json
+// @Produce json
+// @Param tag body object true "Tag, as {\"name\": \"Web Development\"}"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Router /tags [post]
+// @tags Tags
+func (c *TagController) CreateTag(ctx *fiber.Ctx) error {
+	var requestBody struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	tag, err := c.tagService.CreateTag(requestBody.Name)
+	if err != nil {
+		return tagError(ctx, err, "Could not create tag")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(tag)
+}
+
+// GetTag handles fetching a tag.
+// @Summary Get a tag
+// @Description Retrieve a tag by slug or name, with the number of courses it labels
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Success 200 {object} models.TagUsage
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /tags/{slug} [get]
+// @tags Tags
+func (c *TagController) GetTag(ctx *fiber.Ctx) error {
+	tag, err := c.tagService.GetTag(tagParam(ctx, "slug"))
+	if err != nil {
+		return tagError(ctx, err, "Unable to fetch tag")
+	}
+
+	return ctx.JSON(tag)
+}
+
+// RenameTag handles renaming a tag.
+// @Summary Rename a tag
+// @Description Rename a tag, changing its slug along. Fails if another tag has the new slug; merge them instead. The courses it labels are published as updated.
+// @Accept json
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Param tag body object true "New name, as {\"name\": \"Web Development\"}"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 409 {object} object
+// @Failure 500 {object} object
+// @Router /tags/{slug} [put]
+// @tags Tags
+func (c *TagController) RenameTag(ctx *fiber.Ctx) error {
+	var requestBody struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	tag, err := c.tagService.RenameTag(tagParam(ctx, "slug"), requestBody.Name, ctx.Get(events.CorrelationHeader))
+	if err != nil {
+		return tagError(ctx, err, "Could not rename tag")
+	}
+
+	return ctx.JSON(tag)
+}
+
+// DeleteTag handles deleting a tag.
+// @Summary Delete a tag
+// @Description Detach a tag from its courses and delete it. The courses it labeled are published as updated.
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Success 200 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /tags/{slug} [delete]
+// @tags Tags
+func (c *TagController) DeleteTag(ctx *fiber.Ctx) error {
+	slug := tagParam(ctx, "slug")
+	if err := c.tagService.DeleteTag(slug, ctx.Get(events.CorrelationHeader)); err != nil {
+		return tagError(ctx, err, "Could not delete tag")
+	}
+
+	return ctx.JSON(fiber.Map{"message": "Tag deleted successfully", "slug": models.TagSlug(slug)})
+}
+
+// MergeTag handles merging a tag into another.
+// @Summary Merge tags
+// @Description Move the courses of a tag to another tag and delete it. The courses it labeled are published as updated.
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug of the tag merged away"
+// @Param merge body object true "Tag to merge into, as {\"into\": \"web-development\"}"
+// @Success 200 {object} models.TagUsage
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /tags/{slug}/merge [post]
+// @tags Tags
+func (c *TagController) MergeTag(ctx *fiber.Ctx) error {
+	var requestBody struct {
+		Into string `json:"into"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if requestBody.Into == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tag to merge into is required"})
+	}
+
+	tag, err := c.tagService.MergeTag(tagParam(ctx, "slug"), requestBody.Into, ctx.Get(events.CorrelationHeader))
+	if err != nil {
+		return tagError(ctx, err, "Could not merge tags")
+	}
+
+	return ctx.JSON(tag)
+}
+
+// ListTagCourses handles listing the courses of a tag.
+// @Summary List the courses of a tag
+// @Description Retrieve a page of the courses a tag labels, sub-courses included. Pages are read by cursor, or from offset when given. The response carries the total count and links to the next and previous pages.
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Param sort query string false "Sort by title, created_at or category (default created_at)"
+// @Param order query string false "asc or desc (default asc)"
+// @Param cursor query string false "Cursor returned with the previous page"
+// @Param offset query int false "Rows to skip, instead of a cursor"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /tags/{slug}/courses [get]
+// @tags Tags
+func (c *TagController) ListTagCourses(ctx *fiber.Ctx) error {
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	listing, err := c.tagService.ListTagCourses(tagParam(ctx, "slug"), options)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	}
+	if err != nil {
+		return listingError(ctx, err, "Failed to list courses")
+	}
+
+	return respondWithListing(ctx, listing)
+}
+
+// AttachCourseTags handles tagging a course.
+// @Summary Tag a course
+// @Description Attach tags to a course by name, creating the tags that do not exist. Tags already attached are kept.
+// @Accept json
+// @Produce json
+// @Param id path uint true "Course ID"
+// @Param tags body object true "Tag names, as {\"tags\": [\"Go\", \"Web Development\"]}"
+// @Success 200 {object} models.Course
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /course/{id}/tags [post]
+// @tags Tags
+func (c *TagController) AttachCourseTags(ctx *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid course ID"})
+	}
+
+	var requestBody struct {
+		Tags []string `json:"tags"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if len(requestBody.Tags) == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tags are required"})
+	}
+
+	course, err := c.tagService.AttachTags(uint(courseID), requestBody.Tags, ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+	}
+	if err != nil {
+		return tagError(ctx, err, "Could not tag course")
+	}
+
+	return ctx.JSON(course)
+}
+
+// DetachCourseTag handles untagging a course.
+// @Summary Untag a course
+// @Description Detach a tag, by slug or name, from a course. The tag itself is kept.
+// @Produce json
+// @Param id path uint true "Course ID"
+// @Param tag path string true "Tag slug or name"
+// @Success 200 {object} models.Course
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /course/{id}/tags/{tag} [delete]
+// @tags Tags
+func (c *TagController) DetachCourseTag(ctx *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid course ID"})
+	}
+
+	course, err := c.tagService.DetachTag(uint(courseID), tagParam(ctx, "tag"), ctx.Get(events.CorrelationHeader))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+	}
+	if errors.Is(err, services.ErrTagNotOnCourse) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course does not carry this tag"})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not untag course"})
+	}
+
+	return ctx.JSON(course)
+}
+
+// tagParam returns a tag path parameter, which may be a name with escaped characters.
+func tagParam(ctx *fiber.Ctx, key string) string {
+	value := ctx.Params(key)
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// tagError answers the errors shared by tag changes.
+func tagError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, models.ErrInvalidTagName):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tag names need a letter or digit"})
+	case errors.Is(err, services.ErrSameTag):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot merge a tag into itself"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	case errors.Is(err, services.ErrTagExists):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A tag with this slug already exists"})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"course/services"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseListOptions reads the paging and sorting parameters of a listing.
+func parseListOptions(ctx *fiber.Ctx) (services.ListOptions, error) {
+	options := services.ListOptions{
+		Sort:   ctx.Query("sort"),
+		Order:  ctx.Query("order"),
+		Cursor: ctx.Query("cursor"),
+	}
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return options, errors.New("Invalid limit")
+		}
+		options.Limit = limit
+	}
+	if value := ctx.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return options, errors.New("Invalid offset")
+		}
+		if options.Cursor != "" {
+			return options, errors.New("Use either offset or cursor")
+		}
+		options.Offset = &offset
+	}
+	return options, nil
+}
+
+// respondWithListing answers with a page of a listing and the links to the pages around it, which
+// keep the query of the request.
+func respondWithListing[T any](ctx *fiber.Ctx, listing *services.Listing[T]) error {
+	links := fiber.Map{"next": nil, "prev": nil}
+	if listing.HasNext {
+		links["next"] = pageLink(ctx, listing, listing.NextCursor, 1)
+	}
+	if listing.HasPrev {
+		links["prev"] = pageLink(ctx, listing, listing.PrevCursor, -1)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":        listing.Data,
+		"total":       listing.Total,
+		"limit":       listing.Limit,
+		"offset":      listing.Offset,
+		"next_cursor": listing.NextCursor,
+		"prev_cursor": listing.PrevCursor,
+		"links":       links,
+	})
+}
+
+// pageLink returns the link to the page next to a listing in direction, 1 or -1: by offset for
+// offset listings, otherwise by cursor.
+func pageLink[T any](ctx *fiber.Ctx, listing *services.Listing[T], cursor string, direction int) string {
+	query := url.Values{}
+	for key, value := range ctx.Queries() {
+		query.Set(key, value)
+	}
+	query.Set("limit", strconv.Itoa(listing.Limit))
+	if listing.Offset != nil {
+		query.Set("offset", strconv.Itoa(max(*listing.Offset+direction*listing.Limit, 0)))
+	} else {
+		query.Set("cursor", cursor)
+	}
+	return ctx.Path() + "?" + query.Encode()
+}
+
+// listingError answers the errors shared by listings.
+func listingError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidSort):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid sort or order"})
+	case errors.Is(err, services.ErrInvalidCursor):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"course/consumers"
 
 	"course/models"
+	"course/publishers"
 	"course/routes"
+	"course/services"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -29,10 +31,9 @@ func main() {
 		log.Fatalf("Failed to connect to RabbitMQ: %s", err)
 	}
 	defer rabbitMQConfig.Close()
-	queues := []string{"course_events", "coursePath_events"}
 
-	if err := rabbitMQConfig.DeclareQueues(queues, true); err != nil {
-		log.Fatalf("Failed to declare queue: %s", err)
+	if err := rabbitMQConfig.DeclareTopology(config.Topology); err != nil {
+		log.Fatalf("Failed to declare topology: %s", err)
 	}
 
 	db, err := config.ConnectDatabase()
@@ -40,16 +41,48 @@ func main() {
 		log.Fatalf("Failed to connect to database: %s", err)
 	}
 
-	if err := db.AutoMigrate(&models.Course{}, &models.Instructor{}, &models.Class{}); err != nil {
+	if err := db.AutoMigrate(&models.Course{}, &models.Tag{}, &models.CoursePath{}, &models.Instructor{}, &models.Class{}, &models.OutboxEvent{}, &models.ProcessedEvent{}, &models.Operation{}); err != nil {
 		log.Fatalf("Failed to run migrations: %s", err)
 	}
+	if err := models.MigrateTags(db); err != nil {
+		log.Fatalf("Failed to migrate tags: %s", err)
+	}
+	if err := models.MigrateCourseSearch(db); err != nil {
+		log.Fatalf("Failed to migrate course search: %s", err)
+	}
+
+	retention, err := config.ProcessedEventsRetention()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	consumers.StartProcessedEventLedgerPruner(db, retention)
+
+	writeMode, err := config.GetWriteMode()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
 
+	consumers.StartOperationTracker(rabbitMQConfig, db)
 	consumers.StartCourseEventConsumer(rabbitMQConfig, db)
+	consumers.StartClassProjectionConsumer(rabbitMQConfig, db)
+	if err := rabbitMQConfig.VerifyTopology(config.Topology); err != nil {
+		log.Fatalf("Invalid topology: %s", err)
+	}
+	publishers.StartOutboxPublisher(rabbitMQConfig, db)
+
+	classServiceURL, err := config.ClassServiceURL()
+	if err != nil {
+		log.Fatalf("Failed to read configuration: %s", err)
+	}
+	classProjectionService := services.NewClassProjectionService(db, classServiceURL)
 
 	app := fiber.New()
 	app.Static("/docs", "./public/")
 
-	routes.ClassRoutes(app, rabbitMQConfig, db)
+	routes.ClassRoutes(app, rabbitMQConfig, db, writeMode, classProjectionService)
+	routes.TagRoutes(app, rabbitMQConfig, db)
+	routes.AdminRoutes(app, rabbitMQConfig, classProjectionService)
+	routes.HealthRoutes(app, rabbitMQConfig, db)
 
 	log.Println("Starting server on :3000...")
 	if err := app.Listen(":3000"); err != nil {
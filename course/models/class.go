@@ -2,20 +2,89 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const ClassStatusScheduled = "scheduled"
+
+// Class is the read-only projection of a class of the class service, kept current from its
+// events. Sequence is the revision of the class, so that stale events cannot roll it back. Deleted
+// classes are kept, deleted, so that late events cannot bring them back.
 type Class struct {
-	ID              uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ID              uint      `json:"id" gorm:"primaryKey;autoIncrement:false"`
 	Title           string    `json:"title" gorm:"size:255;not null"`
 	Description     string    `json:"description" gorm:"size:1024"`
 	CompanyID       uint      `json:"company_id" gorm:"not null"`
-	CourseID        uint      `json:"course_id" gorm:"not null"`
+	CourseID        uint      `json:"course_id" gorm:"not null;index"`
 	InstructorID    uint      `json:"instructor_id" gorm:"not null"`
+	ClassTypeID     uint      `json:"class_type_id"`
 	ScheduledAt     time.Time `json:"scheduled_at" gorm:"not null"`
+	TimeZone        string    `json:"time_zone" gorm:"size:64"`
+	LocalStart      string    `json:"local_start" gorm:"size:19"`
 	Duration        uint      `json:"duration" gorm:"not null"`
 	MaxParticipants uint      `json:"max_participants" gorm:"not null"`
-	CurrentEnrolled uint      `json:"current_enrolled" gorm:"default:0"`
 	WaitlistEnabled bool      `json:"waitlist_enabled" gorm:"default:false"`
-	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Online          bool      `json:"online" gorm:"default:false"`
+	Status          string    `json:"status" gorm:"size:20"`
+	Sequence        uint      `json:"sequence" gorm:"default:0"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// TableName keeps the projection apart from the classes of the class service, which may share the
+// database.
+func (Class) TableName() string {
+	return "course_classes"
+}
+
+// projectedClassColumns are the columns a newer revision of a class overwrites.
+var projectedClassColumns = []string{
+	"title", "description", "company_id", "course_id", "instructor_id", "class_type_id",
+	"scheduled_at", "time_zone", "local_start", "duration", "max_participants",
+	"waitlist_enabled", "online", "status", "sequence", "created_at", "updated_at",
+}
+
+// SaveClass inserts or updates the projection of a class, unless it was deleted or already holds a
+// newer revision.
+func SaveClass(db *gorm.DB, class *Class) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns(projectedClassColumns),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+			SQL: "course_classes.deleted_at IS NULL AND course_classes.sequence <= excluded.sequence",
+		}}},
+	}).Create(class).Error
+}
+
+// DeleteClass marks the projection of a class deleted.
+func DeleteClass(db *gorm.DB, id uint) error {
+	// A class deleted before its creation arrived is remembered too.
+	tombstone := Class{ID: id, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"deleted_at"}),
+	}).Create(&tombstone).Error
+}
+
+// ClearClasses empties the projection, deleted classes included.
+func ClearClasses(db *gorm.DB) error {
+	return db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&Class{}).Error
+}
+
+// GetUpcomingCourseClasses returns up to limit scheduled classes of a course not over at now, by start.
+func GetUpcomingCourseClasses(db *gorm.DB, courseID uint, now time.Time, limit int) ([]Class, error) {
+	var classes []Class
+	err := db.Where("course_id = ? AND status = ?", courseID, ClassStatusScheduled).
+		Where("scheduled_at + duration * interval '1 minute' > ?", now).
+		Order("scheduled_at, id").
+		Limit(limit).
+		Find(&classes).Error
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
 }
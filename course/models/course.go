@@ -6,9 +6,11 @@ import (
 	"gorm.io/gorm"
 )
 
+// Tag labels courses. Slug is its normalised name, unique across tags; see TagSlug.
 type Tag struct {
 	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	Name      string    `json:"name" gorm:"size:255;not null"`
+	Slug      string    `json:"slug" gorm:"size:255"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -46,12 +48,34 @@ type Instructor struct {
 	Courses   []Course  `json:"courses" gorm:"many2many:course_instructors;constraint:OnDelete:CASCADE;"`
 }
 
+// CreateCourse inserts a course, attaching its tags by name and creating those that do not exist.
 func CreateCourse(db *gorm.DB, course *Course) error {
-	return db.Create(course).Error
+	if len(course.Tags) > 0 {
+		names := make([]string, 0, len(course.Tags))
+		for _, tag := range course.Tags {
+			names = append(names, tag.Name)
+		}
+		tags, err := ResolveTags(db, names)
+		if err != nil {
+			return err
+		}
+		course.Tags = tags
+	}
+	return db.Omit("Tags.*").Create(course).Error
 }
 
+func GetCourseByID(db *gorm.DB, courseID uint) (*Course, error) {
+	var course Course
+	if err := db.Preload("SubCourses").Preload("Instructors").Preload("Tags").First(&course, courseID).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+// UpdateCourse applies the non-zero fields of updatedData to a course. Tags are attached and
+// detached on their own, so those of updatedData are ignored.
 func UpdateCourse(db *gorm.DB, courseID uint, updatedData *Course) error {
-	return db.Model(&Course{}).Where("id = ?", courseID).Updates(updatedData).Error
+	return db.Model(&Course{}).Where("id = ?", courseID).Omit("Tags").Updates(updatedData).Error
 }
 
 func DeleteCourse(db *gorm.DB, courseID uint) error {
@@ -69,6 +93,14 @@ func CreateCoursePath(db *gorm.DB, coursePath *CoursePath) error {
 	return db.Create(coursePath).Error
 }
 
+func GetCoursePathByID(db *gorm.DB, coursePathID uint) (*CoursePath, error) {
+	var coursePath CoursePath
+	if err := db.Preload("Courses").First(&coursePath, coursePathID).Error; err != nil {
+		return nil, err
+	}
+	return &coursePath, nil
+}
+
 func UpdateCoursePath(db *gorm.DB, coursePathID uint, updatedData *CoursePath) error {
 	return db.Model(&CoursePath{}).Where("id = ?", coursePathID).Updates(updatedData).Error
 }
@@ -76,3 +108,113 @@ func UpdateCoursePath(db *gorm.DB, coursePathID uint, updatedData *CoursePath) e
 func DeleteCoursePath(db *gorm.DB, coursePathID uint) error {
 	return db.Where("id = ?", coursePathID).Delete(&CoursePath{}).Error
 }
+
+// CourseSortColumns are the columns courses may be sorted by.
+var CourseSortColumns = []string{"title", "created_at", "category"}
+
+// CourseFilter narrows the courses returned by ListCourses. Zero values are ignored, except a nil
+// ParentCourseID, which keeps top-level courses only unless IncludeSubCourses is set. Tag matches
+// tags by name or slug.
+type CourseFilter struct {
+	Category           string
+	Tag                string
+	InstructorID       uint
+	ParentCourseID     *uint
+	IncludeSubCourses  bool
+	MinEnrollmentLimit *int
+	MaxEnrollmentLimit *int
+}
+
+func (f CourseFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Category != "" {
+		query = query.Where("courses.category = ?", f.Category)
+	}
+	if f.Tag != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM course_tags JOIN tags ON tags.id = course_tags.tag_id
+			WHERE course_tags.course_id = courses.id AND tags.slug = ?)`, TagSlug(f.Tag))
+	}
+	if f.InstructorID != 0 {
+		query = query.Where(`EXISTS (SELECT 1 FROM course_instructors
+			WHERE course_instructors.course_id = courses.id AND course_instructors.instructor_id = ?)`, f.InstructorID)
+	}
+	if f.ParentCourseID != nil {
+		query = query.Where("courses.parent_course_id = ?", *f.ParentCourseID)
+	} else if !f.IncludeSubCourses {
+		query = query.Where("courses.parent_course_id IS NULL")
+	}
+	if f.MinEnrollmentLimit != nil {
+		query = query.Where("courses.enrollment_limit >= ?", *f.MinEnrollmentLimit)
+	}
+	if f.MaxEnrollmentLimit != nil {
+		query = query.Where("courses.enrollment_limit <= ?", *f.MaxEnrollmentLimit)
+	}
+	return query
+}
+
+// ListCourses returns a page of the courses matching filter, with their instructors and
+// sub-courses and tags, and how many courses match in all.
+func ListCourses(db *gorm.DB, filter CourseFilter, page Page) ([]Course, int64, error) {
+	var total int64
+	if err := filter.apply(db.Model(&Course{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var courses []Course
+	query := filter.apply(db.Preload("SubCourses.Instructors").Preload("Instructors").Preload("Tags"))
+	if err := page.apply(query, "courses").Find(&courses).Error; err != nil {
+		return nil, 0, err
+	}
+	return courses, total, nil
+}
+
+// CursorAt returns the position of a course in a listing sorted by column.
+func (c Course) CursorAt(column string) Cursor {
+	switch column {
+	case "title":
+		return Cursor{Value: c.Title, ID: c.ID}
+	case "category":
+		return Cursor{Value: c.Category, ID: c.ID}
+	default:
+		return Cursor{Value: c.CreatedAt, ID: c.ID}
+	}
+}
+
+// CoursePathSortColumns are the columns course paths may be sorted by.
+var CoursePathSortColumns = []string{"title", "created_at"}
+
+// CoursePathFilter narrows the course paths returned by ListCoursePaths. Zero values are ignored.
+type CoursePathFilter struct {
+	CourseID uint
+}
+
+func (f CoursePathFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.CourseID != 0 {
+		query = query.Where(`EXISTS (SELECT 1 FROM path_courses
+			WHERE path_courses.course_path_id = course_paths.id AND path_courses.course_id = ?)`, f.CourseID)
+	}
+	return query
+}
+
+// ListCoursePaths returns a page of the course paths matching filter, with their courses, and how
+// many course paths match in all.
+func ListCoursePaths(db *gorm.DB, filter CoursePathFilter, page Page) ([]CoursePath, int64, error) {
+	var total int64
+	if err := filter.apply(db.Model(&CoursePath{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var coursePaths []CoursePath
+	query := filter.apply(db.Preload("Courses"))
+	if err := page.apply(query, "course_paths").Find(&coursePaths).Error; err != nil {
+		return nil, 0, err
+	}
+	return coursePaths, total, nil
+}
+
+// CursorAt returns the position of a course path in a listing sorted by column.
+func (p CoursePath) CursorAt(column string) Cursor {
+	if column == "title" {
+		return Cursor{Value: p.Title, ID: p.ID}
+	}
+	return Cursor{Value: p.CreatedAt, ID: p.ID}
+}
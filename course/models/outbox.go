@@ -0,0 +1,90 @@
+package models
+
+import (
+	"leecho/events"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEvent is an event waiting to be published, written in the same transaction as the change it describes.
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventID       string     `json:"event_id" gorm:"size:36;not null;uniqueIndex"`
+	EventType     string     `json:"event_type" gorm:"size:100;not null"`
+	Body          string     `json:"body" gorm:"type:jsonb;not null"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:pending;index:idx_outbox_events_due,priority:1"`
+	Attempts      uint       `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"last_error" gorm:"size:1024"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_outbox_events_due,priority:2"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// EnqueueEvent writes an event to the outbox. Pass the transaction of the domain change so both commit together.
+func EnqueueEvent[T any](db *gorm.DB, event events.Envelope[T]) error {
+	body, err := event.Encode()
+	if err != nil {
+		return err
+	}
+	return db.Create(&OutboxEvent{
+		EventID:       event.ID,
+		EventType:     string(event.Type),
+		Body:          string(body),
+		Status:        OutboxPending,
+		NextAttemptAt: event.OccurredAt,
+	}).Error
+}
+
+// ClaimDueOutboxEvents locks up to limit pending events that are due, skipping rows locked by another relay.
+// It must run inside a transaction.
+func ClaimDueOutboxEvents(tx *gorm.DB, now time.Time, limit int) ([]OutboxEvent, error) {
+	var outboxEvents []OutboxEvent
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", OutboxPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&outboxEvents).Error
+	if err != nil {
+		return nil, err
+	}
+	return outboxEvents, nil
+}
+
+func MarkOutboxEventSent(db *gorm.DB, id uint, sentAt time.Time) error {
+	return db.Model(&OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":   OutboxSent,
+		"sent_at":  sentAt,
+		"attempts": gorm.Expr("attempts + 1"),
+	}).Error
+}
+
+// MarkOutboxEventAttemptFailed records a failed publish, scheduling the next attempt or
+// giving up when nextAttemptAt is nil.
+func MarkOutboxEventAttemptFailed(db *gorm.DB, id uint, cause error, nextAttemptAt *time.Time) error {
+	updates := map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": truncate(cause.Error(), 1024),
+	}
+	if nextAttemptAt != nil {
+		updates["next_attempt_at"] = *nextAttemptAt
+	} else {
+		updates["status"] = OutboxFailed
+	}
+	return db.Model(&OutboxEvent{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return value[:limit]
+}
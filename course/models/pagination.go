@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Cursor is the position of a row in a listing: its value of the sort column, then its ID.
+type Cursor struct {
+	Value interface{}
+	ID    uint
+}
+
+// Page selects the rows of a listing sorted by the Sort column: those after After or before Before,
+// or those from Offset, up to Limit. Rows before a cursor are returned nearest first.
+type Page struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	After  *Cursor
+	Before *Cursor
+}
+
+// apply orders, positions and limits query by the page, its columns qualified with table. Sort
+// must have been checked against the columns the listing may be sorted by.
+func (p Page) apply(query *gorm.DB, table string) *gorm.DB {
+	column := table + "." + p.Sort
+	id := table + ".id"
+
+	// Reading backwards from a cursor reverses the order.
+	descending := p.Desc != (p.Before != nil)
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	query = query.Order(fmt.Sprintf("%s %s, %s %s", column, direction, id, direction))
+
+	switch {
+	case p.After != nil || p.Before != nil:
+		cursor := p.After
+		if cursor == nil {
+			cursor = p.Before
+		}
+		operator := ">"
+		if descending {
+			operator = "<"
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", column, id, operator), cursor.Value, cursor.ID)
+	case p.Offset > 0:
+		query = query.Offset(p.Offset)
+	}
+	return query.Limit(p.Limit)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessedEvent records that a consumer handled an event, so that redeliveries are skipped.
+type ProcessedEvent struct {
+	Consumer    string    `json:"consumer" gorm:"primaryKey;size:100"`
+	EventID     string    `json:"event_id" gorm:"primaryKey;size:36"`
+	EventType   string    `json:"event_type" gorm:"size:100;not null"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"not null;index"`
+}
+
+// RecordProcessedEvent adds an event to the ledger of a consumer. It returns false when the
+// event was already recorded. Call it in the transaction of the change the event causes.
+func RecordProcessedEvent(tx *gorm.DB, consumer, eventID, eventType string) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ProcessedEvent{
+		Consumer:    consumer,
+		EventID:     eventID,
+		EventType:   eventType,
+		ProcessedAt: time.Now().UTC(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// PruneProcessedEvents deletes the ledger entries older than the given time.
+func PruneProcessedEvents(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("processed_at < ?", before).Delete(&ProcessedEvent{})
+	return result.RowsAffected, result.Error
+}
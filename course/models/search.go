@@ -0,0 +1,247 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// searchConfig is the text search configuration courses are indexed and searched with.
+const searchConfig = "english"
+
+// Courses are searched through two columns outside the Course model, written only by
+// RefreshCourseSearch: search_vector weighs the title first, then the category and tags, then the
+// instructors and the description; search_text holds the same words for snippets. The words of
+// every indexed course are kept in course_search_words to correct misspelt queries.
+var searchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`ALTER TABLE courses ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`ALTER TABLE courses ADD COLUMN IF NOT EXISTS search_text text`,
+	`CREATE INDEX IF NOT EXISTS idx_courses_search_vector ON courses USING GIN (search_vector)`,
+	`CREATE TABLE IF NOT EXISTS course_search_words (word text PRIMARY KEY)`,
+	`CREATE INDEX IF NOT EXISTS idx_course_search_words_trgm ON course_search_words USING GIN (word gin_trgm_ops)`,
+}
+
+// MigrateCourseSearch adds the search columns and indexes, and indexes the courses not indexed yet.
+func MigrateCourseSearch(db *gorm.DB) error {
+	for _, statement := range searchMigrations {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	var ids []uint
+	if err := db.Model(&Course{}).Where("search_vector IS NULL").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return refreshCourseSearch(db, ids)
+}
+
+// RefreshCourseSearch indexes a course and its sub-courses, which are written with it, again.
+func RefreshCourseSearch(db *gorm.DB, courseID uint) error {
+	var ids []uint
+	err := db.Raw(`WITH RECURSIVE tree AS (
+			SELECT id FROM courses WHERE id = ?
+			UNION
+			SELECT courses.id FROM courses JOIN tree ON courses.parent_course_id = tree.id
+		)
+		SELECT id FROM tree`, courseID).Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return refreshCourseSearch(db, ids)
+}
+
+func refreshCourseSearch(db *gorm.DB, ids []uint) error {
+	err := db.Exec(`UPDATE courses SET search_vector = document.vector, search_text = document.text
+		FROM (
+			SELECT courses.id,
+				setweight(to_tsvector('`+searchConfig+`', courses.title), 'A') ||
+				setweight(to_tsvector('`+searchConfig+`', coalesce(courses.category, '') || ' ' || coalesce(tags.names, '')), 'B') ||
+				setweight(to_tsvector('`+searchConfig+`', coalesce(instructors.names, '')), 'C') ||
+				setweight(to_tsvector('`+searchConfig+`', coalesce(courses.description, '')), 'D') AS vector,
+				concat_ws(' ', courses.title, courses.category, tags.names, instructors.names, courses.description) AS text
+			FROM courses
+			LEFT JOIN LATERAL (
+				SELECT string_agg(tags.name, ' ') AS names FROM course_tags
+				JOIN tags ON tags.id = course_tags.tag_id
+				WHERE course_tags.course_id = courses.id
+			) tags ON true
+			LEFT JOIN LATERAL (
+				SELECT string_agg(instructors.name, ' ') AS names FROM course_instructors
+				JOIN instructors ON instructors.id = course_instructors.instructor_id
+				WHERE course_instructors.course_id = courses.id
+			) instructors ON true
+			WHERE courses.id IN ?
+		) document
+		WHERE courses.id = document.id`, ids).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Exec(`INSERT INTO course_search_words (word)
+		SELECT DISTINCT word FROM courses, regexp_split_to_table(lower(courses.search_text), '[^[:alnum:]]+') AS word
+		WHERE courses.id IN ? AND length(word) >= 3
+		ON CONFLICT DO NOTHING`, ids).Error
+}
+
+// CourseSearch is a full-text search of courses, narrowed by category and tag, by name or slug,
+// when set.
+type CourseSearch struct {
+	Query    string
+	Category string
+	Tag      string
+}
+
+// CourseHit is a course matching a search, with its relevance and its title and description with
+// the matched words highlighted.
+type CourseHit struct {
+	CourseID   uint       `json:"-"`
+	Course     *Course    `json:"course" gorm:"-"`
+	Rank       float64    `json:"rank"`
+	Highlights Highlights `json:"highlights" gorm:"embedded"`
+}
+
+// Highlights are the title and description of a course with the words matching a search marked.
+type Highlights struct {
+	Title       string `json:"title" gorm:"column:title_highlight"`
+	Description string `json:"description" gorm:"column:description_highlight"`
+}
+
+// FacetCount is how many courses matching a search share a value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// HighlightStart and HighlightStop surround the matched words in highlights.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// CorrectSearchWords replaces the words of query unknown to the index with the indexed words
+// closest to them, by trigram similarity. Words without a close enough match are kept, lowercased.
+func CorrectSearchWords(db *gorm.DB, query string) (string, error) {
+	words := strings.Fields(strings.ToLower(query))
+	for i, word := range words {
+		// Operators and quoted phrases are left as typed.
+		if len(word) < 3 || strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+			continue
+		}
+		var matches []string
+		err := db.Raw(`SELECT word FROM course_search_words WHERE word % ?
+			ORDER BY word = ? DESC, similarity(word, ?) DESC, word LIMIT 1`, word, word, word).
+			Scan(&matches).Error
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > 0 {
+			words[i] = matches[0]
+		}
+	}
+	return strings.Join(words, " "), nil
+}
+
+// searchQuery returns the text search query matching query or its correction.
+func searchQuery(search CourseSearch, corrected string) (string, []interface{}) {
+	return "(websearch_to_tsquery('" + searchConfig + "', ?) || websearch_to_tsquery('" + searchConfig + "', ?))",
+		[]interface{}{search.Query, corrected}
+}
+
+func (s CourseSearch) matching(db *gorm.DB, corrected string) *gorm.DB {
+	tsquery, args := searchQuery(s, corrected)
+	query := db.Model(&Course{}).Where("courses.search_vector @@ "+tsquery, args...)
+	if s.Category != "" {
+		query = query.Where("courses.category = ?", s.Category)
+	}
+	if s.Tag != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM course_tags JOIN tags ON tags.id = course_tags.tag_id
+			WHERE course_tags.course_id = courses.id AND tags.slug = ?)`, TagSlug(s.Tag))
+	}
+	return query
+}
+
+// SearchCourses returns the courses matching search or its corrected query from offset, most
+// relevant first, with their instructors and tags, and how many match in all.
+func SearchCourses(db *gorm.DB, search CourseSearch, corrected string, offset, limit int) ([]CourseHit, int64, error) {
+	var total int64
+	if err := search.matching(db, corrected).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tsquery, args := searchQuery(search, corrected)
+	options := "StartSel=" + HighlightStart + ", StopSel=" + HighlightStop + ", MaxWords=35, MinWords=15, MaxFragments=2"
+	var selectArgs []interface{}
+	selectArgs = append(selectArgs, args...)
+	selectArgs = append(selectArgs, args...)
+	selectArgs = append(selectArgs, options)
+	selectArgs = append(selectArgs, args...)
+	selectArgs = append(selectArgs, options)
+
+	var hits []CourseHit
+	err := search.matching(db, corrected).
+		Select(`courses.id AS course_id,
+			ts_rank_cd(courses.search_vector, `+tsquery+`) AS rank,
+			ts_headline('`+searchConfig+`', courses.title, `+tsquery+`, ?) AS title_highlight,
+			ts_headline('`+searchConfig+`', coalesce(courses.description, ''), `+tsquery+`, ?) AS description_highlight`,
+			selectArgs...).
+		Order("rank DESC, courses.id").
+		Offset(offset).
+		Limit(limit).
+		Scan(&hits).Error
+	if err != nil || len(hits) == 0 {
+		return hits, total, err
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.CourseID)
+	}
+	var courses []Course
+	if err := db.Preload("Instructors").Preload("Tags").Where("id IN ?", ids).Find(&courses).Error; err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]*Course, len(courses))
+	for i := range courses {
+		byID[courses[i].ID] = &courses[i]
+	}
+	for i := range hits {
+		hits[i].Course = byID[hits[i].CourseID]
+	}
+	return hits, total, nil
+}
+
+// CountCategoryFacets returns how many courses matching search fall in each category, most first.
+func CountCategoryFacets(db *gorm.DB, search CourseSearch, corrected string) ([]FacetCount, error) {
+	var facets []FacetCount
+	err := search.matching(db, corrected).
+		Select("courses.category AS value, count(*) AS count").
+		Group("courses.category").
+		Order("count DESC, value").
+		Scan(&facets).Error
+	if err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// CountTagFacets returns how many courses matching search carry each tag, most first.
+func CountTagFacets(db *gorm.DB, search CourseSearch, corrected string) ([]FacetCount, error) {
+	var facets []FacetCount
+	err := search.matching(db, corrected).
+		Joins("JOIN course_tags ON course_tags.course_id = courses.id").
+		Joins("JOIN tags ON tags.id = course_tags.tag_id").
+		Select("tags.name AS value, count(DISTINCT courses.id) AS count").
+		Group("tags.name").
+		Order("count DESC, value").
+		Scan(&facets).Error
+	if err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
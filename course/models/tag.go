@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidTagName = errors.New("invalid tag name")
+
+// TagUsage is a tag with the number of courses it labels.
+type TagUsage struct {
+	Tag
+	CourseCount int64 `json:"course_count"`
+}
+
+// NormalizeTagName trims a tag name and collapses its inner spaces.
+func NormalizeTagName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// TagSlug returns the slug of a tag name: its letters and digits lowercased, runs of anything else
+// replaced with a dash. Names differing only in case, spacing or punctuation share a slug, and a
+// slug is its own slug.
+func TagSlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
+}
+
+// MigrateTags gives the tags created before slugs existed theirs, merging the tags whose names share
+// a slug into the oldest, then makes slugs unique.
+func MigrateTags(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tags []Tag
+		if err := tx.Where("slug IS NULL OR slug = ''").Order("id").Find(&tags).Error; err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			name := NormalizeTagName(tag.Name)
+			slug := TagSlug(name)
+			if slug == "" {
+				// Names without letters or digits are kept, under their ID.
+				slug = fmt.Sprintf("tag-%d", tag.ID)
+			}
+			existing, err := GetTagBySlug(tx, slug)
+			switch {
+			case err == nil:
+				if err := MergeTags(tx, tag.ID, existing.ID); err != nil {
+					return err
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err := tx.Model(&Tag{}).Where("id = ?", tag.ID).Updates(map[string]interface{}{"name": name, "slug": slug}).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_slug ON tags (slug)`).Error
+	})
+}
+
+// CreateTag inserts a tag under its normalised name and slug. It fails with ErrInvalidTagName when
+// the name has no letters or digits.
+func CreateTag(db *gorm.DB, tag *Tag) error {
+	tag.Name = NormalizeTagName(tag.Name)
+	tag.Slug = TagSlug(tag.Name)
+	if tag.Slug == "" {
+		return ErrInvalidTagName
+	}
+	return db.Create(tag).Error
+}
+
+// GetTagBySlug returns the tag with the slug of slug, so that a tag may be named by its name too.
+func GetTagBySlug(db *gorm.DB, slug string) (*Tag, error) {
+	var tag Tag
+	if err := db.Where("slug = ?", TagSlug(slug)).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ResolveTags returns the tags with the given names, creating those that do not exist. Names
+// sharing a slug resolve to one tag.
+func ResolveTags(db *gorm.DB, names []string) ([]Tag, error) {
+	tags := make([]Tag, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		tag := Tag{Name: NormalizeTagName(name)}
+		tag.Slug = TagSlug(tag.Name)
+		if tag.Slug == "" {
+			return nil, ErrInvalidTagName
+		}
+		if seen[tag.Slug] {
+			continue
+		}
+		seen[tag.Slug] = true
+
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "slug"}},
+			DoNothing: true,
+		}).Create(&tag).Error
+		if err != nil {
+			return nil, err
+		}
+		// The tag existed already: the insert did nothing.
+		if tag.ID == 0 {
+			existing, err := GetTagBySlug(db, tag.Slug)
+			if err != nil {
+				return nil, err
+			}
+			tag = *existing
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag, moving its slug along.
+func RenameTag(db *gorm.DB, tagID uint, name string) error {
+	name = NormalizeTagName(name)
+	slug := TagSlug(name)
+	if slug == "" {
+		return ErrInvalidTagName
+	}
+	return db.Model(&Tag{}).Where("id = ?", tagID).Updates(map[string]interface{}{"name": name, "slug": slug}).Error
+}
+
+// MergeTags moves the courses of a tag to another one, then deletes it.
+func MergeTags(db *gorm.DB, sourceID, targetID uint) error {
+	err := db.Exec(`INSERT INTO course_tags (course_id, tag_id)
+		SELECT course_id, ? FROM course_tags WHERE tag_id = ?
+		ON CONFLICT DO NOTHING`, targetID, sourceID).Error
+	if err != nil {
+		return err
+	}
+	return DeleteTag(db, sourceID)
+}
+
+// DeleteTag detaches a tag from its courses and deletes it.
+func DeleteTag(db *gorm.DB, tagID uint) error {
+	if err := db.Exec(`DELETE FROM course_tags WHERE tag_id = ?`, tagID).Error; err != nil {
+		return err
+	}
+	return db.Delete(&Tag{}, tagID).Error
+}
+
+// AttachTags labels a course with the given tags. Tags already attached are left as they are.
+func AttachTags(db *gorm.DB, courseID uint, tags []Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+	}
+	return db.Exec(`INSERT INTO course_tags (course_id, tag_id)
+		SELECT ?, id FROM tags WHERE id IN ?
+		ON CONFLICT DO NOTHING`, courseID, ids).Error
+}
+
+// DetachTag removes a tag from a course. It reports whether the course carried the tag.
+func DetachTag(db *gorm.DB, courseID, tagID uint) (bool, error) {
+	result := db.Exec(`DELETE FROM course_tags WHERE course_id = ? AND tag_id = ?`, courseID, tagID)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetTagCourseIDs returns the IDs of the courses a tag labels.
+func GetTagCourseIDs(db *gorm.DB, tagID uint) ([]uint, error) {
+	var ids []uint
+	if err := db.Raw(`SELECT course_id FROM course_tags WHERE tag_id = ? ORDER BY course_id`, tagID).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// TouchCourses bumps the update time of the given courses, whose tags changed.
+func TouchCourses(db *gorm.DB, courseIDs []uint) error {
+	if len(courseIDs) == 0 {
+		return nil
+	}
+	return db.Model(&Course{}).Where("id IN ?", courseIDs).UpdateColumn("updated_at", time.Now()).Error
+}
+
+func tagUsage(db *gorm.DB) *gorm.DB {
+	return db.Table("tags").
+		Select("tags.*, count(course_tags.course_id) AS course_count").
+		Joins("LEFT JOIN course_tags ON course_tags.tag_id = tags.id").
+		Group("tags.id")
+}
+
+// ListTagUsage returns the tags with how many courses each labels, by name or, with byUsage, most
+// used first. A positive limit keeps that many tags.
+func ListTagUsage(db *gorm.DB, byUsage bool, limit int) ([]TagUsage, error) {
+	query := tagUsage(db)
+	if byUsage {
+		query = query.Order("course_count DESC, tags.name")
+	} else {
+		query = query.Order("tags.name")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var usages []TagUsage
+	if err := query.Scan(&usages).Error; err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetTagUsage returns a tag with how many courses it labels.
+func GetTagUsage(db *gorm.DB, tagID uint) (*TagUsage, error) {
+	var usage TagUsage
+	result := tagUsage(db).Where("tags.id = ?", tagID).Scan(&usage)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &usage, nil
+}
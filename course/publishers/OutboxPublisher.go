@@ -0,0 +1,89 @@
+package publishers
+
+import (
+	"context"
+	"course/config"
+	"course/models"
+	"leecho/events"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	outboxPollInterval = time.Second
+	outboxBatchSize    = 50
+	outboxMaxAttempts  = 10
+	outboxBaseBackoff  = time.Second
+	outboxMaxBackoff   = 5 * time.Minute
+	// outboxPublishTimeout bounds the wait for the broker to confirm one event.
+	outboxPublishTimeout = 10 * time.Second
+)
+
+// StartOutboxPublisher relays pending outbox events to RabbitMQ in the background.
+func StartOutboxPublisher(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := publishDueOutboxEvents(rabbitMQConfig, db); err != nil {
+				log.Printf("Failed to relay outbox events: %s", err)
+			}
+		}
+	}()
+
+	log.Println("Relaying outbox events.")
+}
+
+func publishDueOutboxEvents(rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) error {
+	// Leave the events pending rather than spending their attempts while the broker is away.
+	if !rabbitMQConfig.IsConnected() {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		outboxEvents, err := models.ClaimDueOutboxEvents(tx, time.Now(), outboxBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, outboxEvent := range outboxEvents {
+			ctx, cancel := context.WithTimeout(context.Background(), outboxPublishTimeout)
+			publishErr := rabbitMQConfig.PublishEvent(ctx, events.Type(outboxEvent.EventType), outboxEvent.EventID, []byte(outboxEvent.Body))
+			cancel()
+			if publishErr == nil {
+				if err := models.MarkOutboxEventSent(tx, outboxEvent.ID, time.Now()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var nextAttemptAt *time.Time
+			if outboxEvent.Attempts+1 < outboxMaxAttempts {
+				next := time.Now().Add(outboxBackoff(outboxEvent.Attempts))
+				nextAttemptAt = &next
+				log.Printf("Failed to publish outbox event %s, retrying at %s: %s", outboxEvent.EventID, next.Format(time.RFC3339), publishErr)
+			} else {
+				log.Printf("Giving up on outbox event %s after %d attempts: %s", outboxEvent.EventID, outboxEvent.Attempts+1, publishErr)
+			}
+			if err := models.MarkOutboxEventAttemptFailed(tx, outboxEvent.ID, publishErr, nextAttemptAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// outboxBackoff doubles the delay after each failed attempt, up to outboxMaxBackoff.
+func outboxBackoff(attempts uint) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := uint(0); i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
@@ -5,4 +5,6 @@ type CourseCreateRequest struct {
 	Description     string `json:"description" gorm:"size:1024"`
 	Category        string `json:"category" gorm:"size:100;not null"`
 	EnrollmentLimit int    `json:"enrollment_limit"`
+	// Tags are attached by name; those that do not exist are created.
+	Tags []string `json:"tags"`
 }
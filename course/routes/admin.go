@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"course/config"
+	"course/controllers"
+	"course/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AdminRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, classProjectionService *services.ClassProjectionService) {
+	deadLetterController := controllers.NewDeadLetterController(rabbitMQConfig)
+	courseClassController := controllers.NewCourseClassController(classProjectionService)
+
+	app.Get("/admin/dead-letters/:queue", deadLetterController.ListDeadLetters)
+	app.Post("/admin/dead-letters/:queue/replay", deadLetterController.ReplayDeadLetters)
+	app.Get("/admin/dead-letters/:queue/:eventId", deadLetterController.GetDeadLetter)
+	app.Delete("/admin/dead-letters/:queue", deadLetterController.PurgeDeadLetters)
+
+	app.Post("/admin/projections/classes/rebuild", courseClassController.RebuildClassProjection)
+}
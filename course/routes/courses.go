@@ -11,13 +11,16 @@ import (
 	"gorm.io/gorm"
 )
 
-func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB, writeMode config.WriteMode, classProjectionService *services.ClassProjectionService) {
 
 	courseService := services.NewCourseService(db, rabbitMQConfig)
-	classController := controllers.NewCourseController(courseService, rabbitMQConfig)
+	classController := controllers.NewCourseController(courseService, rabbitMQConfig, writeMode)
+	operationController := controllers.NewOperationController(courseService)
+
+	courseClassController := controllers.NewCourseClassController(classProjectionService)
 
 	coursePathService := services.NewCoursePathService(db, rabbitMQConfig)
-	coursePathController := controllers.NewCoursePathController(coursePathService, rabbitMQConfig)
+	coursePathController := controllers.NewCoursePathController(coursePathService, rabbitMQConfig, writeMode)
 
 	app.Get("/test", func(c *fiber.Ctx) error {
 		return c.SendString("ok")
@@ -28,7 +31,9 @@ func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm
 	app.Delete("/courses", classController.DeleteAllCourses)
 
 	app.Get("/courses", classController.ListAllCourses)
+	app.Get("/courses/search", classController.SearchCourses)
 	app.Get("/course/:id", classController.GetCourseWithSubcourses)
+	app.Get("/course/:id/classes", courseClassController.ListCourseClasses)
 
 	app.Post("/coursepath", coursePathController.CreateCoursePath)
 	app.Put("/coursepath/:id", coursePathController.UpdateCoursePath)
@@ -36,6 +41,9 @@ func ClassRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm
 	app.Get("/coursepaths", coursePathController.ListAllCoursePaths)
 	app.Get("/coursepath/:id", coursePathController.GetCoursePathByID)
 
+	app.Get("/operations/:id", operationController.GetOperation)
+	app.Get("/operations/:id/stream", operationController.StreamOperation)
+
 	app.Get("/swagger/*", swagger.New(swagger.Config{
 		URL: "http://localhost:3000/docs/swagger.json",
 	}))
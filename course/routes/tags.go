@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"course/config"
+	"course/controllers"
+	"course/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TagRoutes(app *fiber.App, rabbitMQConfig *config.RabbitMQConfig, db *gorm.DB) {
+	tagService := services.NewTagService(db, rabbitMQConfig)
+	tagController := controllers.NewTagController(tagService)
+
+	app.Get("/tags", tagController.ListTags)
+	app.Post("/tags", tagController.CreateTag)
+	app.Get("/tags/:slug", tagController.GetTag)
+	app.Put("/tags/:slug", tagController.RenameTag)
+	app.Delete("/tags/:slug", tagController.DeleteTag)
+	app.Post("/tags/:slug/merge", tagController.MergeTag)
+	app.Get("/tags/:slug/courses", tagController.ListTagCourses)
+
+	app.Post("/course/:id/tags", tagController.AttachCourseTags)
+	app.Delete("/course/:id/tags/:tag", tagController.DetachCourseTag)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"course/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultClassPageSize = 20
+	MaxClassPageSize     = 100
+
+	// classServiceTimeout bounds each request to the class service.
+	classServiceTimeout = 30 * time.Second
+)
+
+type ClassProjectionService struct {
+	DB              *gorm.DB
+	classServiceURL string
+	client          *http.Client
+}
+
+func NewClassProjectionService(db *gorm.DB, classServiceURL string) *ClassProjectionService {
+	return &ClassProjectionService{
+		DB:              db,
+		classServiceURL: classServiceURL,
+		client:          &http.Client{Timeout: classServiceTimeout},
+	}
+}
+
+// ListUpcomingClasses returns up to limit scheduled classes of a course that are not over yet.
+func (s *ClassProjectionService) ListUpcomingClasses(courseID uint, limit int) ([]models.Class, error) {
+	if limit <= 0 {
+		limit = DefaultClassPageSize
+	}
+	if limit > MaxClassPageSize {
+		limit = MaxClassPageSize
+	}
+
+	if _, err := models.GetCourseByID(s.DB, courseID); err != nil {
+		return nil, err
+	}
+	return models.GetUpcomingCourseClasses(s.DB, courseID, time.Now(), limit)
+}
+
+// Rebuild replaces the projection of classes with every class listed by the class service, in one
+// transaction so that readers keep the previous projection until it completes. Events consumed
+// meanwhile wait for the transaction and are applied on top. It returns how many classes were
+// projected.
+func (s *ClassProjectionService) Rebuild() (int, error) {
+	projected := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE course_classes IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := models.ClearClasses(tx); err != nil {
+			return err
+		}
+
+		cursor := ""
+		for {
+			classes, next, err := s.fetchClasses(cursor)
+			if err != nil {
+				return err
+			}
+			for i := range classes {
+				if err := models.SaveClass(tx, &classes[i]); err != nil {
+					return err
+				}
+			}
+			projected += len(classes)
+			if next == "" {
+				return nil
+			}
+			cursor = next
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return projected, nil
+}
+
+// fetchClasses reads a page of classes from the class service.
+func (s *ClassProjectionService) fetchClasses(cursor string) ([]models.Class, string, error) {
+	query := url.Values{"limit": {fmt.Sprint(MaxClassPageSize)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	response, err := s.client.Get(s.classServiceURL + "/classes?" + query.Encode())
+	if err != nil {
+		return nil, "", fmt.Errorf("listing classes: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("listing classes: class service answered %s", response.Status)
+	}
+
+	var page struct {
+		Data       []models.Class `json:"data"`
+		NextCursor string         `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&page); err != nil {
+		return nil, "", fmt.Errorf("decoding classes: %w", err)
+	}
+	return page.Data, page.NextCursor, nil
+}
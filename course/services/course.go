@@ -3,10 +3,19 @@ package services
 import (
 	"course/config"
 	"course/models"
+	"leecho/events"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	// OperationPollInterval is how often a waiting client's operation is read again.
+	OperationPollInterval = 500 * time.Millisecond
+	// MaxOperationWait bounds how long a request may wait for an operation to finish.
+	MaxOperationWait = time.Minute
+)
+
 type CourseService struct {
 	DB             *gorm.DB
 	rabbitMQConfig *config.RabbitMQConfig
@@ -18,40 +27,162 @@ func NewCourseService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *Cours
 		rabbitMQConfig: rabbitMQConfig,
 	}
 }
-func (s *CourseService) CreateCourse(course *models.Course) error {
-	return models.CreateCourse(s.DB, course)
+
+// CreateCourse persists a course and records its course.created event in the outbox, in one transaction.
+func (s *CourseService) CreateCourse(course *models.Course, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CreateCourse(tx, course); err != nil {
+			return err
+		}
+		persisted, err := models.GetCourseByID(tx, course.ID)
+		if err != nil {
+			return err
+		}
+		*course = *persisted
+
+		event := events.New(events.CourseCreated, events.SourceCourseService, *course).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// UpdateCourse applies the non-zero fields of updatedData to an existing course, reloads it into
+// updatedData and records its course.updated event in the outbox, in one transaction.
+func (s *CourseService) UpdateCourse(courseID uint, updatedData *models.Course, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCourseByID(tx, courseID); err != nil {
+			return err
+		}
+		if err := models.UpdateCourse(tx, courseID, updatedData); err != nil {
+			return err
+		}
+		persisted, err := models.GetCourseByID(tx, courseID)
+		if err != nil {
+			return err
+		}
+		*updatedData = *persisted
+
+		event := events.New(events.CourseUpdated, events.SourceCourseService, *updatedData).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// DeleteCourse deletes an existing course and records its course.deleted event in the outbox, in one transaction.
+func (s *CourseService) DeleteCourse(courseID uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCourseByID(tx, courseID); err != nil {
+			return err
+		}
+		if err := models.DeleteCourse(tx, courseID); err != nil {
+			return err
+		}
+
+		event := events.New(events.CourseDeleted, events.SourceCourseService, events.Deleted{ID: courseID}).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
+}
+
+// DeleteMultipleCourses deletes the given courses and records one course.deleted event per course
+// in the outbox, in one transaction.
+func (s *CourseService) DeleteMultipleCourses(courseIDs []uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.DeleteMultipleCourses(tx, courseIDs); err != nil {
+			return err
+		}
+		for _, id := range courseIDs {
+			event := events.New(events.CourseDeleted, events.SourceCourseService, events.Deleted{ID: id}).
+				WithCorrelationID(correlationID).
+				MarkPersisted()
+			if err := models.EnqueueEvent(tx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RequestCourseCreation records a course.created command for the consumer to apply.
+func (s *CourseService) RequestCourseCreation(course models.Course, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CourseCreated, events.SourceCourseService, course).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+// RequestCourseUpdate records a course.updated command for the consumer to apply.
+func (s *CourseService) RequestCourseUpdate(course models.Course, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CourseUpdated, events.SourceCourseService, course).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
 }
 
-func (s *CourseService) UpdateCourse(courseID uint, updatedData *models.Course) error {
-	return models.UpdateCourse(s.DB, courseID, updatedData)
+// RequestCourseDeletion records a course.deleted command for the consumer to apply.
+func (s *CourseService) RequestCourseDeletion(courseID uint, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CourseDeleted, events.SourceCourseService, events.Deleted{ID: courseID}).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
 }
 
-func (s *CourseService) DeleteCourse(courseID uint) error {
-	return models.DeleteCourse(s.DB, courseID)
+// RequestMultipleCourseDeletion records one course.deleted command per course, all or none.
+func (s *CourseService) RequestMultipleCourseDeletion(courseIDs []uint, correlationID string) ([]models.Operation, error) {
+	operations := make([]models.Operation, 0, len(courseIDs))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range courseIDs {
+			event := events.New(events.CourseDeleted, events.SourceCourseService, events.Deleted{ID: id}).
+				WithCorrelationID(correlationID)
+			operation, err := models.EnqueueOperation(tx, event)
+			if err != nil {
+				return err
+			}
+			operations = append(operations, *operation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
 }
 
-func (s *CourseService) DeleteMultipleCourses(courseIDs []uint) error {
-	return models.DeleteMultipleCourses(s.DB, courseIDs)
+func (s *CourseService) GetOperation(id string) (*models.Operation, error) {
+	return models.GetOperationByID(s.DB, id)
+}
+
+// WaitForOperation returns the operation as soon as it is done, or as it stands once wait has elapsed.
+func (s *CourseService) WaitForOperation(id string, wait time.Duration) (*models.Operation, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		operation, err := models.GetOperationByID(s.DB, id)
+		if err != nil || operation.Done() || time.Now().Add(OperationPollInterval).After(deadline) {
+			return operation, err
+		}
+		time.Sleep(OperationPollInterval)
+	}
 }
 
 func (s *CourseService) GetCourseWithSubcourses(courseID uint) (*models.Course, error) {
 	var course models.Course
-	if err := s.DB.Preload("SubCourses").First(&course, courseID).Error; err != nil {
+	if err := s.DB.Preload("SubCourses.Instructors").Preload("Instructors").Preload("Tags").First(&course, courseID).Error; err != nil {
 		return nil, err
 	}
 	return &course, nil
 }
 
-func (s *CourseService) ListAllCourses() ([]models.Course, error) {
-	var courses []models.Course
-
-	if err := s.DB.Preload("SubCourses").
-		Where("parent_course_id IS NULL").
-		Find(&courses).Error; err != nil {
+// ListCourses returns a page of the courses matching filter, with their instructors, sub-courses and tags.
+func (s *CourseService) ListCourses(filter models.CourseFilter, options ListOptions) (*Listing[models.Course], error) {
+	page, err := newPage(options, models.CourseSortColumns)
+	if err != nil {
 		return nil, err
 	}
-
-	return courses, nil
+	courses, total, err := models.ListCourses(s.DB, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	return newListing(courses, total, page, options), nil
 }
 
 func (s *CourseService) ListAllCoursePaths() ([]models.CoursePath, error) {
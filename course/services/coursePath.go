@@ -3,6 +3,7 @@ package services
 import (
 	"course/config"
 	"course/models"
+	"leecho/events"
 
 	"gorm.io/gorm"
 )
@@ -19,20 +20,85 @@ func NewCoursePathService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *C
 	}
 }
 
-func (s *CoursePathService) CreateCourse(course *models.Course) error {
-	return models.CreateCourse(s.DB, course)
+// CreateCoursePath persists a course path and records its course_path.created event in the outbox, in one transaction.
+func (s *CoursePathService) CreateCoursePath(coursePath *models.CoursePath, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CreateCoursePath(tx, coursePath); err != nil {
+			return err
+		}
+		persisted, err := models.GetCoursePathByID(tx, coursePath.ID)
+		if err != nil {
+			return err
+		}
+		*coursePath = *persisted
+
+		event := events.New(events.CoursePathCreated, events.SourceCourseService, *coursePath).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
 }
 
-func (s *CoursePathService) UpdateCourse(courseID uint, updatedData *models.Course) error {
-	return models.UpdateCourse(s.DB, courseID, updatedData)
+// UpdateCoursePath applies the non-zero fields of updatedData to an existing course path, reloads it
+// into updatedData and records its course_path.updated event in the outbox, in one transaction.
+func (s *CoursePathService) UpdateCoursePath(coursePathID uint, updatedData *models.CoursePath, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCoursePathByID(tx, coursePathID); err != nil {
+			return err
+		}
+		if err := models.UpdateCoursePath(tx, coursePathID, updatedData); err != nil {
+			return err
+		}
+		persisted, err := models.GetCoursePathByID(tx, coursePathID)
+		if err != nil {
+			return err
+		}
+		*updatedData = *persisted
+
+		event := events.New(events.CoursePathUpdated, events.SourceCourseService, *updatedData).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
 }
 
-func (s *CoursePathService) DeleteCourse(courseID uint) error {
-	return models.DeleteCourse(s.DB, courseID)
+// DeleteCoursePath deletes an existing course path and records its course_path.deleted event in the
+// outbox, in one transaction.
+func (s *CoursePathService) DeleteCoursePath(coursePathID uint, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCoursePathByID(tx, coursePathID); err != nil {
+			return err
+		}
+		if err := models.DeleteCoursePath(tx, coursePathID); err != nil {
+			return err
+		}
+
+		event := events.New(events.CoursePathDeleted, events.SourceCourseService, events.Deleted{ID: coursePathID}).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		return models.EnqueueEvent(tx, event)
+	})
 }
 
-func (s *CoursePathService) DeleteMultipleCourses(courseIDs []uint) error {
-	return models.DeleteMultipleCourses(s.DB, courseIDs)
+// RequestCoursePathCreation records a course_path.created command for the consumer to apply.
+func (s *CoursePathService) RequestCoursePathCreation(coursePath models.CoursePath, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CoursePathCreated, events.SourceCourseService, coursePath).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+// RequestCoursePathUpdate records a course_path.updated command for the consumer to apply.
+func (s *CoursePathService) RequestCoursePathUpdate(coursePath models.CoursePath, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CoursePathUpdated, events.SourceCourseService, coursePath).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
+}
+
+// RequestCoursePathDeletion records a course_path.deleted command for the consumer to apply.
+func (s *CoursePathService) RequestCoursePathDeletion(coursePathID uint, correlationID string) (*models.Operation, error) {
+	event := events.New(events.CoursePathDeleted, events.SourceCourseService, events.Deleted{ID: coursePathID}).
+		WithCorrelationID(correlationID)
+	return models.EnqueueOperation(s.DB, event)
 }
 
 func (s *CoursePathService) GetCourseWithSubcourses(courseID uint) (*models.Course, error) {
@@ -55,14 +121,17 @@ func (s *CoursePathService) ListAllCourses() ([]models.Course, error) {
 	return courses, nil
 }
 
-func (s *CoursePathService) ListAllCoursePaths() ([]models.CoursePath, error) {
-	var coursePaths []models.CoursePath
-
-	if err := s.DB.Preload("Courses").Find(&coursePaths).Error; err != nil {
+// ListCoursePaths returns a page of the course paths matching filter, with their courses.
+func (s *CoursePathService) ListCoursePaths(filter models.CoursePathFilter, options ListOptions) (*Listing[models.CoursePath], error) {
+	page, err := newPage(options, models.CoursePathSortColumns)
+	if err != nil {
 		return nil, err
 	}
-
-	return coursePaths, nil
+	coursePaths, total, err := models.ListCoursePaths(s.DB, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	return newListing(coursePaths, total, page, options), nil
 }
 
 func (s *CoursePathService) GetCoursePathByID(coursePathID uint) (*models.CoursePath, error) {
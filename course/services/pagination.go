@@ -0,0 +1,181 @@
+package services
+
+import (
+	"course/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// defaultSort is the column listings are sorted by unless told otherwise.
+	defaultSort = "created_at"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// ListOptions are how a client pages through a listing: from an offset when one is given,
+// otherwise by cursor, starting from the first page.
+type ListOptions struct {
+	Sort   string
+	Order  string
+	Limit  int
+	Offset *int
+	Cursor string
+}
+
+// Listing is a page of a listing, with how many rows match in all. Cursor pages carry the cursors
+// of the pages around them; HasNext and HasPrev tell whether those pages exist.
+type Listing[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasNext    bool   `json:"-"`
+	HasPrev    bool   `json:"-"`
+}
+
+type positioned interface {
+	CursorAt(column string) models.Cursor
+}
+
+// pageCursor is a cursor as handed to clients. It is bound to the order of the listing it was
+// taken from.
+type pageCursor struct {
+	Sort   string          `json:"s"`
+	Desc   bool            `json:"d,omitempty"`
+	Before bool            `json:"b,omitempty"`
+	Value  json.RawMessage `json:"v"`
+	ID     uint            `json:"id"`
+}
+
+// newPage checks options against the columns a listing may be sorted by and returns the page they
+// select, reading one row more than asked to tell whether another page follows.
+func newPage(options ListOptions, columns []string) (models.Page, error) {
+	page := models.Page{Sort: options.Sort, Limit: options.Limit}
+	if page.Sort == "" {
+		page.Sort = defaultSort
+	}
+	if !slices.Contains(columns, page.Sort) {
+		return page, ErrInvalidSort
+	}
+	switch options.Order {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, ErrInvalidSort
+	}
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageSize
+	}
+	if page.Limit > MaxPageSize {
+		page.Limit = MaxPageSize
+	}
+
+	switch {
+	case options.Offset != nil:
+		page.Offset = max(*options.Offset, 0)
+	case options.Cursor != "":
+		cursor, before, err := decodePageCursor(options.Cursor, page)
+		if err != nil {
+			return page, err
+		}
+		if before {
+			page.Before = cursor
+		} else {
+			page.After = cursor
+		}
+	}
+	page.Limit++
+	return page, nil
+}
+
+// newListing returns the rows read for page as a listing.
+func newListing[T positioned](rows []T, total int64, page models.Page, options ListOptions) *Listing[T] {
+	limit := page.Limit - 1
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if page.Before != nil {
+		slices.Reverse(rows)
+	}
+
+	listing := &Listing[T]{Data: rows, Total: total, Limit: limit}
+	if options.Offset != nil {
+		offset := page.Offset
+		listing.Offset = &offset
+		listing.HasNext = int64(offset+len(rows)) < total
+		listing.HasPrev = offset > 0
+		return listing
+	}
+
+	switch {
+	case page.After != nil:
+		listing.HasNext, listing.HasPrev = more, true
+	case page.Before != nil:
+		listing.HasNext, listing.HasPrev = true, more
+	default:
+		listing.HasNext = more
+	}
+
+	first, last := page.After, page.Before
+	if len(rows) > 0 {
+		firstCursor, lastCursor := rows[0].CursorAt(page.Sort), rows[len(rows)-1].CursorAt(page.Sort)
+		first, last = &firstCursor, &lastCursor
+	}
+	if listing.HasPrev && first != nil {
+		listing.PrevCursor = encodePageCursor(*first, page, true)
+	}
+	if listing.HasNext && last != nil {
+		listing.NextCursor = encodePageCursor(*last, page, false)
+	}
+	listing.HasPrev = listing.PrevCursor != ""
+	listing.HasNext = listing.NextCursor != ""
+	return listing
+}
+
+func encodePageCursor(cursor models.Cursor, page models.Page, before bool) string {
+	value, _ := json.Marshal(cursor.Value)
+	raw, _ := json.Marshal(pageCursor{Sort: page.Sort, Desc: page.Desc, Before: before, Value: value, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodePageCursor reads a cursor taken from a listing in the order of page.
+func decodePageCursor(encoded string, page models.Page) (*models.Cursor, bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, ErrInvalidCursor
+	}
+	var decoded pageCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.Sort != page.Sort || decoded.Desc != page.Desc {
+		return nil, false, ErrInvalidCursor
+	}
+
+	cursor := &models.Cursor{ID: decoded.ID}
+	if page.Sort == "created_at" {
+		var at time.Time
+		if err := json.Unmarshal(decoded.Value, &at); err != nil {
+			return nil, false, ErrInvalidCursor
+		}
+		cursor.Value = at
+	} else {
+		var value string
+		if err := json.Unmarshal(decoded.Value, &value); err != nil {
+			return nil, false, ErrInvalidCursor
+		}
+		cursor.Value = value
+	}
+	return cursor, decoded.Before, nil
+}
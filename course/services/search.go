@@ -0,0 +1,76 @@
+package services
+
+import (
+	"course/models"
+	"errors"
+	"strings"
+)
+
+var ErrEmptySearch = errors.New("empty search query")
+
+// SearchFacets count the courses matching a search by category and by tag.
+type SearchFacets struct {
+	Category []models.FacetCount `json:"category"`
+	Tags     []models.FacetCount `json:"tags"`
+}
+
+// SearchResult is a page of the courses matching a search, most relevant first. CorrectedQuery is
+// the query with misspelt words corrected, when it differs; courses matching it match too.
+type SearchResult struct {
+	Listing[models.CourseHit]
+	Query          string       `json:"query"`
+	CorrectedQuery string       `json:"corrected_query,omitempty"`
+	Facets         SearchFacets `json:"facets"`
+}
+
+// SearchCourses returns the courses matching search from offset, with the facets of all matches.
+func (s *CourseService) SearchCourses(search models.CourseSearch, offset, limit int) (*SearchResult, error) {
+	search.Query = strings.Join(strings.Fields(search.Query), " ")
+	if search.Query == "" {
+		return nil, ErrEmptySearch
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	offset = max(offset, 0)
+
+	corrected, err := models.CorrectSearchWords(s.DB, search.Query)
+	if err != nil {
+		return nil, err
+	}
+	hits, total, err := models.SearchCourses(s.DB, search, corrected, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := models.CountCategoryFacets(s.DB, search, corrected)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := models.CountTagFacets(s.DB, search, corrected)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Listing: Listing[models.CourseHit]{
+			Data:    hits,
+			Total:   total,
+			Limit:   limit,
+			Offset:  &offset,
+			HasNext: int64(offset+len(hits)) < total,
+			HasPrev: offset > 0,
+		},
+		Query:  search.Query,
+		Facets: SearchFacets{Category: categories, Tags: tags},
+	}
+	if corrected != strings.ToLower(search.Query) {
+		result.CorrectedQuery = corrected
+	}
+	if result.Data == nil {
+		result.Data = []models.CourseHit{}
+	}
+	return result, nil
+}
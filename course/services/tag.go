@@ -0,0 +1,262 @@
+package services
+
+import (
+	"course/config"
+	"course/models"
+	"errors"
+	"leecho/events"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagExists      = errors.New("tag already exists")
+	ErrTagNotOnCourse = errors.New("course does not carry the tag")
+	ErrSameTag        = errors.New("cannot merge a tag into itself")
+)
+
+// TagService manages tags and their courses. Tag changes are written at once whatever the write
+// mode; the courses they change are published as course.updated events, through the outbox.
+type TagService struct {
+	DB             *gorm.DB
+	rabbitMQConfig *config.RabbitMQConfig
+}
+
+func NewTagService(db *gorm.DB, rabbitMQConfig *config.RabbitMQConfig) *TagService {
+	return &TagService{
+		DB:             db,
+		rabbitMQConfig: rabbitMQConfig,
+	}
+}
+
+// ListTags returns the tags with their usage counts, by name or, with byUsage, most used first.
+func (s *TagService) ListTags(byUsage bool, limit int) ([]models.TagUsage, error) {
+	return models.ListTagUsage(s.DB, byUsage, limit)
+}
+
+// GetTag returns the tag with the given slug or name, with its usage count.
+func (s *TagService) GetTag(slug string) (*models.TagUsage, error) {
+	tag, err := models.GetTagBySlug(s.DB, slug)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetTagUsage(s.DB, tag.ID)
+}
+
+// CreateTag creates a tag, unless one with the same slug exists.
+func (s *TagService) CreateTag(name string) (*models.Tag, error) {
+	tag := models.Tag{Name: name}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkTagFree(tx, name, 0); err != nil {
+			return err
+		}
+		return models.CreateTag(tx, &tag)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// RenameTag renames a tag, unless another tag has the slug of the new name, and records the
+// course.updated events of its courses, in one transaction.
+func (s *TagService) RenameTag(slug, name, correlationID string) (*models.Tag, error) {
+	var renamed *models.Tag
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		tag, err := models.GetTagBySlug(tx, slug)
+		if err != nil {
+			return err
+		}
+		if err := checkTagFree(tx, name, tag.ID); err != nil {
+			return err
+		}
+		if err := models.RenameTag(tx, tag.ID, name); err != nil {
+			return err
+		}
+		courseIDs, err := models.GetTagCourseIDs(tx, tag.ID)
+		if err != nil {
+			return err
+		}
+		if err := enqueueCourseUpdates(tx, courseIDs, correlationID); err != nil {
+			return err
+		}
+		renamed, err = models.GetTagBySlug(tx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return renamed, nil
+}
+
+// MergeTag moves the courses of a tag to another one and deletes it, recording the course.updated
+// events of its courses, in one transaction. It returns the tag merged into.
+func (s *TagService) MergeTag(slug, intoSlug, correlationID string) (*models.TagUsage, error) {
+	var merged *models.TagUsage
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		source, err := models.GetTagBySlug(tx, slug)
+		if err != nil {
+			return err
+		}
+		target, err := models.GetTagBySlug(tx, intoSlug)
+		if err != nil {
+			return err
+		}
+		if source.ID == target.ID {
+			return ErrSameTag
+		}
+		courseIDs, err := models.GetTagCourseIDs(tx, source.ID)
+		if err != nil {
+			return err
+		}
+		if err := models.MergeTags(tx, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := enqueueCourseUpdates(tx, courseIDs, correlationID); err != nil {
+			return err
+		}
+		merged, err = models.GetTagUsage(tx, target.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// DeleteTag detaches a tag from its courses and deletes it, recording the course.updated events of
+// its courses, in one transaction.
+func (s *TagService) DeleteTag(slug, correlationID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		tag, err := models.GetTagBySlug(tx, slug)
+		if err != nil {
+			return err
+		}
+		courseIDs, err := models.GetTagCourseIDs(tx, tag.ID)
+		if err != nil {
+			return err
+		}
+		if err := models.DeleteTag(tx, tag.ID); err != nil {
+			return err
+		}
+		return enqueueCourseUpdates(tx, courseIDs, correlationID)
+	})
+}
+
+// ListTagCourses returns a page of the courses a tag labels, sub-courses included.
+func (s *TagService) ListTagCourses(slug string, options ListOptions) (*Listing[models.Course], error) {
+	tag, err := models.GetTagBySlug(s.DB, slug)
+	if err != nil {
+		return nil, err
+	}
+	page, err := newPage(options, models.CourseSortColumns)
+	if err != nil {
+		return nil, err
+	}
+	filter := models.CourseFilter{Tag: tag.Slug, IncludeSubCourses: true}
+	courses, total, err := models.ListCourses(s.DB, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	return newListing(courses, total, page, options), nil
+}
+
+// AttachTags labels a course with tags by name, creating those that do not exist, and records its
+// course.updated event, in one transaction. It returns the course.
+func (s *TagService) AttachTags(courseID uint, names []string, correlationID string) (*models.Course, error) {
+	var course *models.Course
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCourseByID(tx, courseID); err != nil {
+			return err
+		}
+		tags, err := models.ResolveTags(tx, names)
+		if err != nil {
+			return err
+		}
+		if err := models.AttachTags(tx, courseID, tags); err != nil {
+			return err
+		}
+		if err := enqueueCourseUpdates(tx, []uint{courseID}, correlationID); err != nil {
+			return err
+		}
+		course, err = models.GetCourseByID(tx, courseID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+// DetachTag removes a tag, by slug or name, from a course and records its course.updated event, in
+// one transaction. It returns the course.
+func (s *TagService) DetachTag(courseID uint, slug, correlationID string) (*models.Course, error) {
+	var course *models.Course
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetCourseByID(tx, courseID); err != nil {
+			return err
+		}
+		tag, err := models.GetTagBySlug(tx, slug)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTagNotOnCourse
+		}
+		if err != nil {
+			return err
+		}
+		detached, err := models.DetachTag(tx, courseID, tag.ID)
+		if err != nil {
+			return err
+		}
+		if !detached {
+			return ErrTagNotOnCourse
+		}
+		if err := enqueueCourseUpdates(tx, []uint{courseID}, correlationID); err != nil {
+			return err
+		}
+		course, err = models.GetCourseByID(tx, courseID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+// checkTagFree fails with ErrTagExists when a tag other than tagID has the slug of name.
+func checkTagFree(tx *gorm.DB, name string, tagID uint) error {
+	if models.TagSlug(name) == "" {
+		return models.ErrInvalidTagName
+	}
+	existing, err := models.GetTagBySlug(tx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != tagID {
+		return ErrTagExists
+	}
+	return nil
+}
+
+// enqueueCourseUpdates bumps the given courses, whose tags changed, and records a course.updated
+// event for each in the outbox.
+func enqueueCourseUpdates(tx *gorm.DB, courseIDs []uint, correlationID string) error {
+	if err := models.TouchCourses(tx, courseIDs); err != nil {
+		return err
+	}
+	for _, id := range courseIDs {
+		course, err := models.GetCourseByID(tx, id)
+		if err != nil {
+			return err
+		}
+		event := events.New(events.CourseUpdated, events.SourceCourseService, *course).
+			WithCorrelationID(correlationID).
+			MarkPersisted()
+		if err := models.EnqueueEvent(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
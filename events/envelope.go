@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the envelope version written by this package. Consumers reject
+// envelopes with a newer version than they understand.
+const SchemaVersion = 1
+
+var ErrUnsupportedVersion = errors.New("unsupported event schema version")
+
+// Envelope wraps every event exchanged between services. Persisted is set when the source
+// service committed the change before publishing the event: such events are notifications
+// and must not be applied again by the source.
+type Envelope[T any] struct {
+	ID            string    `json:"id"`
+	Type          Type      `json:"type"`
+	Source        string    `json:"source"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	CorrelationID string    `json:"correlation_id"`
+	SchemaVersion int       `json:"schema_version"`
+	Persisted     bool      `json:"persisted"`
+	Payload       T         `json:"payload"`
+}
+
+// Raw is an envelope whose payload has not been decoded yet.
+type Raw = Envelope[json.RawMessage]
+
+// NewID returns a random identifier, as used for event IDs.
+func NewID() string {
+	return uuid.NewString()
+}
+
+// New builds an envelope with a fresh ID. The correlation ID defaults to the event ID.
+func New[T any](eventType Type, source string, payload T) Envelope[T] {
+	id := NewID()
+	return Envelope[T]{
+		ID:            id,
+		Type:          eventType,
+		Source:        source,
+		OccurredAt:    time.Now().UTC(),
+		CorrelationID: id,
+		SchemaVersion: SchemaVersion,
+		Payload:       payload,
+	}
+}
+
+// WithCorrelationID returns a copy of the envelope carrying the given correlation ID,
+// or the envelope unchanged when the ID is empty.
+func (e Envelope[T]) WithCorrelationID(correlationID string) Envelope[T] {
+	if correlationID != "" {
+		e.CorrelationID = correlationID
+	}
+	return e
+}
+
+// MarkPersisted returns a copy of the envelope flagged as describing an already committed change.
+func (e Envelope[T]) MarkPersisted() Envelope[T] {
+	e.Persisted = true
+	return e
+}
+
+// Encode serializes the envelope to JSON.
+func (e Envelope[T]) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Parse decodes the envelope of a message, leaving the payload raw so that
+// consumers can pick the payload type from the event type.
+func Parse(body []byte) (Raw, error) {
+	var raw Raw
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return raw, err
+	}
+	if raw.SchemaVersion < 1 || raw.SchemaVersion > SchemaVersion {
+		return raw, fmt.Errorf("%w: %d", ErrUnsupportedVersion, raw.SchemaVersion)
+	}
+	return raw, nil
+}
+
+// DecodePayload decodes the payload of a raw envelope into T.
+func DecodePayload[T any](raw Raw) (Envelope[T], error) {
+	envelope := Envelope[T]{
+		ID:            raw.ID,
+		Type:          raw.Type,
+		Source:        raw.Source,
+		OccurredAt:    raw.OccurredAt,
+		CorrelationID: raw.CorrelationID,
+		SchemaVersion: raw.SchemaVersion,
+		Persisted:     raw.Persisted,
+	}
+	if err := json.Unmarshal(raw.Payload, &envelope.Payload); err != nil {
+		return envelope, fmt.Errorf("decoding %s payload: %w", raw.Type, err)
+	}
+	return envelope, nil
+}
@@ -0,0 +1,5 @@
+module leecho/events
+
+go 1.23
+
+require github.com/google/uuid v1.5.0
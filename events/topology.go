@@ -0,0 +1,194 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Topic exchanges, one per publishing service.
+const (
+	ClassExchange  = "leecho.class"
+	CourseExchange = "leecho.course"
+)
+
+// exchanges maps every event type to the exchange it is published on.
+var exchanges = map[Type]string{
+	ClassCreated:        ClassExchange,
+	ClassUpdated:        ClassExchange,
+	ClassDeleted:        ClassExchange,
+	ClassCancelled:      ClassExchange,
+	ClassRescheduled:    ClassExchange,
+	ClassSeriesCreated:  ClassExchange,
+	ClassSeriesUpdated:  ClassExchange,
+	ClassSeriesDeleted:  ClassExchange,
+	LearnerEnrolled:     ClassExchange,
+	LearnerWaitlisted:   ClassExchange,
+	LearnerPromoted:     ClassExchange,
+	EnrollmentCancelled: ClassExchange,
+	AttendanceRecorded:  ClassExchange,
+	FeedbackSubmitted:   ClassExchange,
+	CourseCreated:       CourseExchange,
+	CourseUpdated:       CourseExchange,
+	CourseDeleted:       CourseExchange,
+	CoursePathCreated:   CourseExchange,
+	CoursePathUpdated:   CourseExchange,
+	CoursePathDeleted:   CourseExchange,
+}
+
+// ExchangeFor returns the exchange an event type is published on.
+func ExchangeFor(eventType Type) (string, error) {
+	exchange, ok := exchanges[eventType]
+	if !ok {
+		return "", fmt.Errorf("no exchange registered for event type %s", eventType)
+	}
+	return exchange, nil
+}
+
+// RoutingKey returns the routing key an event type is published with.
+func (t Type) RoutingKey() string {
+	return string(t)
+}
+
+// Binding routes the messages of an exchange matching a topic pattern to a queue.
+type Binding struct {
+	Exchange   string
+	RoutingKey string
+}
+
+// Queue is a durable queue and the bindings feeding it.
+type Queue struct {
+	Name     string
+	Bindings []Binding
+}
+
+// Topology is the broker layout of one service: the queues it consumes, the
+// event types its controllers emit and how failed messages are retried.
+type Topology struct {
+	Queues []Queue
+	Emits  []Type
+	Retry  RetryPolicy
+}
+
+// Queue returns the queue of the topology with the given name.
+func (t Topology) Queue(name string) (Queue, bool) {
+	for _, queue := range t.Queues {
+		if queue.Name == name {
+			return queue, true
+		}
+	}
+	return Queue{}, false
+}
+
+// RetryPolicy returns the retry policy of the topology, or DefaultRetryPolicy when unset.
+func (t Topology) RetryPolicy() RetryPolicy {
+	if t.Retry.MaxRetries == 0 {
+		return DefaultRetryPolicy
+	}
+	return t.Retry
+}
+
+// Exchanges returns every exchange the topology publishes to or binds from.
+func (t Topology) Exchanges() ([]string, error) {
+	seen := map[string]bool{}
+	for _, eventType := range t.Emits {
+		exchange, err := ExchangeFor(eventType)
+		if err != nil {
+			return nil, err
+		}
+		seen[exchange] = true
+	}
+	for _, queue := range t.Queues {
+		for _, binding := range queue.Bindings {
+			seen[binding.Exchange] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Routes returns the queues of the topology an event type is delivered to.
+func (t Topology) Routes(eventType Type) []string {
+	exchange, err := ExchangeFor(eventType)
+	if err != nil {
+		return nil
+	}
+
+	var queues []string
+	for _, queue := range t.Queues {
+		for _, binding := range queue.Bindings {
+			if binding.Exchange == exchange && MatchRoutingKey(binding.RoutingKey, eventType.RoutingKey()) {
+				queues = append(queues, queue.Name)
+				break
+			}
+		}
+	}
+	return queues
+}
+
+// MatchRoutingKey reports whether a routing key matches a topic binding pattern,
+// where "*" stands for exactly one word and "#" for zero or more words.
+func MatchRoutingKey(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+	}
+}
+
+// Headers set on messages that are retried or dead-lettered.
+const (
+	RetryCountHeader     = "x-retry-count"
+	LastErrorHeader      = "x-last-error"
+	DeadLetteredAtHeader = "x-dead-lettered-at"
+)
+
+// RetryPolicy bounds the redeliveries of a message whose handler failed.
+// The nth retry is delayed by InitialDelay * 2^(n-1).
+type RetryPolicy struct {
+	MaxRetries   int
+	InitialDelay time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 5, InitialDelay: time.Second}
+
+// Delay returns how long the given retry attempt, starting at 1, waits before redelivery.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	return p.InitialDelay << (attempt - 1)
+}
+
+// RetryQueueName is the delay queue holding messages of a queue waiting for the given retry attempt.
+func RetryQueueName(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+// DeadLetterExchangeName is the exchange receiving the messages a queue gave up on.
+func DeadLetterExchangeName(queue string) string {
+	return queue + ".dlx"
+}
+
+// DeadLetterQueueName is the queue holding the messages a queue gave up on.
+func DeadLetterQueueName(queue string) string {
+	return queue + ".dead"
+}
@@ -0,0 +1,64 @@
+package events
+
+// Type identifies what happened, as "<entity>.<verb>".
+type Type string
+
+// Sources, written in Envelope.Source.
+const (
+	SourceClassService  = "class_service"
+	SourceCourseService = "course_service"
+)
+
+// Class service events.
+const (
+	ClassCreated Type = "class.created"
+	ClassUpdated Type = "class.updated"
+	ClassDeleted Type = "class.deleted"
+
+	ClassCancelled   Type = "class.cancelled"
+	ClassRescheduled Type = "class.rescheduled"
+)
+
+// Class series events, emitted by the class service.
+const (
+	ClassSeriesCreated Type = "class_series.created"
+	ClassSeriesUpdated Type = "class_series.updated"
+	ClassSeriesDeleted Type = "class_series.deleted"
+)
+
+// Enrollment events, emitted by the class service.
+const (
+	LearnerEnrolled     Type = "enrollment.enrolled"
+	LearnerWaitlisted   Type = "enrollment.waitlisted"
+	LearnerPromoted     Type = "enrollment.promoted"
+	EnrollmentCancelled Type = "enrollment.cancelled"
+)
+
+// Attendance events, emitted by the class service.
+const (
+	AttendanceRecorded Type = "attendance.recorded"
+)
+
+// Feedback events, emitted by the class service.
+const (
+	FeedbackSubmitted Type = "feedback.submitted"
+)
+
+// Course service events.
+const (
+	CourseCreated     Type = "course.created"
+	CourseUpdated     Type = "course.updated"
+	CourseDeleted     Type = "course.deleted"
+	CoursePathCreated Type = "course_path.created"
+	CoursePathUpdated Type = "course_path.updated"
+	CoursePathDeleted Type = "course_path.deleted"
+)
+
+// Deleted is the payload of every "*.deleted" event.
+type Deleted struct {
+	ID uint `json:"id"`
+}
+
+// CorrelationHeader is the HTTP header whose value, when present, is carried as the
+// correlation ID of the events a request produces.
+const CorrelationHeader = "X-Correlation-ID"